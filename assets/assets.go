@@ -0,0 +1,169 @@
+// Package assets finds the game's files and decodes its images and sounds into
+// types that do not depend on a graphics or sound API. Frontends only upload
+// the decoded data to their backends.
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/blob"
+	"github.com/gonutz/payload"
+
+	"github.com/gonutz/ld36/audio"
+)
+
+// ErrNotFound is returned, wrapped with the file's name, for files that none
+// of a Loader's sources have.
+var ErrNotFound = errors.New("asset not found")
+
+// Options are the places to look for files. Every file is taken from the first
+// of these that has it, in the order of the fields. Empty fields are skipped.
+type Options struct {
+	// Embedded is a blob compiled into the executable.
+	Embedded []byte
+	// Payload looks for a blob appended to the executable, see
+	// github.com/gonutz/payload.
+	Payload bool
+	// Dir is a folder with the files, e.g. the rsc folder after make_assets
+	// was run.
+	Dir string
+	// FS is any file system with the files in its root.
+	FS fs.FS
+}
+
+// Loader reads files from its sources.
+type Loader struct {
+	sources []source
+}
+
+type source struct {
+	// name describes the source in error messages
+	name     string
+	readFile func(name string) ([]byte, error)
+}
+
+// NewLoader opens the sources in the options. A blob that cannot be decoded is
+// an error, a missing payload is not since executables built without one read
+// their files from the other sources.
+func NewLoader(o Options) (*Loader, error) {
+	var l Loader
+	if len(o.Embedded) > 0 {
+		if err := l.addBlob("embedded blob", o.Embedded); err != nil {
+			return nil, err
+		}
+	}
+	if o.Payload {
+		data, err := payload.Read()
+		if err == nil {
+			if err := l.addBlob("blob in executable", data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if o.Dir != "" {
+		l.sources = append(l.sources, source{
+			name: "folder " + o.Dir,
+			readFile: func(name string) ([]byte, error) {
+				return ioutil.ReadFile(filepath.Join(o.Dir, name))
+			},
+		})
+	}
+	if o.FS != nil {
+		l.sources = append(l.sources, source{
+			name: "file system",
+			readFile: func(name string) ([]byte, error) {
+				return fs.ReadFile(o.FS, name)
+			},
+		})
+	}
+	return &l, nil
+}
+
+func (l *Loader) addBlob(name string, data []byte) error {
+	b, err := blob.Read(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to decode %v: %v", name, err)
+	}
+	l.sources = append(l.sources, source{
+		name: fmt.Sprintf("%v (%v items)", name, b.ItemCount()),
+		readFile: func(id string) ([]byte, error) {
+			data, ok := b.GetByID(id)
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return data, nil
+		},
+	})
+	return nil
+}
+
+// Sources describes where the loader looks for files, in order, e.g. for the
+// log.
+func (l *Loader) Sources() []string {
+	names := make([]string, len(l.sources))
+	for i, s := range l.sources {
+		names[i] = s.name
+	}
+	return names
+}
+
+// ReadFile returns the contents of the named file from the first source that
+// has it. Errors other than a missing file stop the search.
+func (l *Loader) ReadFile(name string) ([]byte, error) {
+	for _, s := range l.sources {
+		data, err := s.readFile(name)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unable to read %v from %v: %w", name, s.name, err)
+		}
+	}
+	return nil, fmt.Errorf("%v: %w", name, ErrNotFound)
+}
+
+// LoadImage decodes the PNG file id+".png". The pixels are returned as they
+// are in the file, the images that make_assets generates have red and blue
+// swapped for Direct3D.
+func (l *Loader) LoadImage(id string) (*image.NRGBA, error) {
+	data, err := l.ReadFile(id + ".png")
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image %v.png is not a valid png: %v", id, err)
+	}
+	return toNRGBA(img), nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+	nrgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return nrgba
+}
+
+// LoadWave decodes the WAV file id+".wav".
+func (l *Loader) LoadWave(id string) (*audio.Wave, error) {
+	data, err := l.ReadFile(id + ".wav")
+	if err != nil {
+		return nil, err
+	}
+	wave, err := audio.DecodeWav(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read wave %v: %v", id, err)
+	}
+	return wave, nil
+}
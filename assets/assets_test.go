@@ -0,0 +1,95 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gonutz/ld36/audio"
+)
+
+func TestFilesComeFromTheFirstSourceThatHasThem(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "info.json"), []byte("dir"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewLoader(Options{
+		Dir: dir,
+		FS: fstest.MapFS{
+			"info.json":   {Data: []byte("fs")},
+			"level_0.tmx": {Data: []byte("level")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"info.json":   "dir",
+		"level_0.tmx": "level",
+	} {
+		data, err := l.ReadFile(name)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if string(data) != want {
+			t.Errorf("%v: want %q but have %q", name, want, data)
+		}
+	}
+
+	if _, err := l.ReadFile("missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound for a missing file but have %v", err)
+	}
+}
+
+func TestImagesAreDecodedToNRGBA(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 2, 1))
+	gray.Pix = []byte{0, 255}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := NewLoader(Options{FS: fstest.MapFS{
+		"rock.png":   {Data: buf.Bytes()},
+		"broken.png": {Data: []byte("not a png")},
+	}})
+
+	img, err := l.LoadImage("rock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 2 || img.NRGBAAt(1, 0) != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("wrong image %v %v", img.Bounds(), img.Pix)
+	}
+
+	if _, err := l.LoadImage("broken"); err == nil {
+		t.Error("invalid png was decoded")
+	}
+	if _, err := l.LoadImage("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound for a missing image but have %v", err)
+	}
+}
+
+func TestWavesAreDecoded(t *testing.T) {
+	var buf bytes.Buffer
+	if err := audio.WriteWav(&buf, 22050, []int16{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := NewLoader(Options{FS: fstest.MapFS{"cloud.wav": {Data: buf.Bytes()}}})
+
+	w, err := l.LoadWave("cloud")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.SamplesPerSec != 22050 || len(w.Data) != 8 {
+		t.Errorf("wrong wave: %v Hz, %v bytes", w.SamplesPerSec, len(w.Data))
+	}
+	if _, err := l.LoadWave("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound for a missing wave but have %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package atlas packs many small images into a few large ones, called
+// atlases, so that backends switch textures less often. A manifest remembers
+// where each image went. make_assets packs the sprites, frontends use the
+// manifest to draw them from their atlas.
+package atlas
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/gonutz/ld36/game"
+)
+
+// ManifestFile is the name of the manifest that make_assets writes next to the
+// atlases.
+const ManifestFile = "atlas.json"
+
+// Manifest tells in which atlas and where in it each sprite is.
+type Manifest struct {
+	// Atlases are the image IDs of all atlases, without the .png extension.
+	Atlases []string
+	// Sprites maps the sprites' image IDs to their places.
+	Sprites map[string]Sprite
+}
+
+// Sprite is the part of an atlas that holds one image.
+type Sprite struct {
+	Atlas string
+	Rect  game.Rectangle
+}
+
+// ReadManifest decodes a manifest file.
+func ReadManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid atlas manifest: %v", err)
+	}
+	return &m, nil
+}
+
+// Load returns the image with the given ID. Sprites in the manifest are taken
+// from their atlas which is loaded with loadImage, all other images are loaded
+// directly. loadImage should only load each image once. A nil manifest loads
+// all images directly.
+func (m *Manifest) Load(id string, loadImage func(id string) game.Image) game.Image {
+	if m != nil {
+		if s, ok := m.Sprites[id]; ok {
+			return SubImage{Image: loadImage(s.Atlas), Rect: s.Rect}
+		}
+	}
+	return loadImage(id)
+}
+
+// SubImage draws only the Rect part of Image and acts like an image of that
+// size. Source rectangles are relative to Rect and are cut off at its edges.
+type SubImage struct {
+	Image game.Image
+	Rect  game.Rectangle
+}
+
+func (img SubImage) DrawAt(x, y int) {
+	img.Image.DrawRectAt(x, y, img.Rect)
+}
+
+func (img SubImage) DrawAtEx(x, y int, options game.DrawOptions) {
+	img.Image.DrawRectAtEx(x, y, img.Rect, options)
+}
+
+func (img SubImage) DrawRectAt(x, y int, source game.Rectangle) {
+	img.Image.DrawRectAt(x, y, img.toAtlas(source))
+}
+
+func (img SubImage) DrawRectAtEx(x, y int, source game.Rectangle, options game.DrawOptions) {
+	img.Image.DrawRectAtEx(x, y, img.toAtlas(source), options)
+}
+
+func (img SubImage) Size() (int, int) {
+	return img.Rect.W, img.Rect.H
+}
+
+// toAtlas moves the source rectangle from sprite to atlas coordinates.
+func (img SubImage) toAtlas(source game.Rectangle) game.Rectangle {
+	r := image.Rect(source.X, source.Y, source.X+source.W, source.Y+source.H)
+	r = r.Intersect(image.Rect(0, 0, img.Rect.W, img.Rect.H))
+	return game.Rectangle{
+		X: img.Rect.X + r.Min.X,
+		Y: img.Rect.Y + r.Min.Y,
+		W: r.Dx(),
+		H: r.Dy(),
+	}
+}
+
+// Padding is the number of pixels around every sprite in an atlas. The
+// sprite's edge pixels are repeated into it so that texture filtering at the
+// edges does not blend in the neighboring sprites, e.g. when the game is
+// zoomed.
+const Padding = 2
+
+// Pack puts the images into as few atlases as possible, none of which is wider
+// or higher than maxSize. The atlases are named prefix+"_0", prefix+"_1" and
+// so on. Images are placed in rows, the highest first, which works well for
+// sprites of similar heights.
+func Pack(images map[string]image.Image, prefix string, maxSize int) ([]*image.NRGBA, *Manifest, error) {
+	ids := make([]string, 0, len(images))
+	for id := range images {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		hi, hj := images[ids[i]].Bounds().Dy(), images[ids[j]].Bounds().Dy()
+		if hi != hj {
+			return hi > hj
+		}
+		return ids[i] < ids[j]
+	})
+
+	m := &Manifest{Sprites: make(map[string]Sprite)}
+	// places are the padded areas of the sprites in each atlas
+	var places [][]image.Rectangle
+	var x, y, rowHeight int
+	for _, id := range ids {
+		b := images[id].Bounds()
+		w, h := b.Dx()+2*Padding, b.Dy()+2*Padding
+		if w > maxSize || h > maxSize {
+			return nil, nil, fmt.Errorf(
+				"image %v of size %vx%v does not fit into an atlas of size %v",
+				id, b.Dx(), b.Dy(), maxSize,
+			)
+		}
+		if x+w > maxSize {
+			x, y, rowHeight = 0, y+rowHeight, 0
+		}
+		if len(places) == 0 || y+h > maxSize {
+			name := fmt.Sprintf("%v_%d", prefix, len(places))
+			m.Atlases = append(m.Atlases, name)
+			places = append(places, nil)
+			x, y, rowHeight = 0, 0, 0
+		}
+		atlas := len(places) - 1
+		places[atlas] = append(places[atlas], image.Rect(x, y, x+w, y+h))
+		m.Sprites[id] = Sprite{
+			Atlas: m.Atlases[atlas],
+			Rect:  game.Rectangle{X: x + Padding, Y: y + Padding, W: b.Dx(), H: b.Dy()},
+		}
+		x += w
+		if h > rowHeight {
+			rowHeight = h
+		}
+	}
+
+	atlases := make([]*image.NRGBA, len(places))
+	for i, rects := range places {
+		var size image.Rectangle
+		for _, r := range rects {
+			size = size.Union(r)
+		}
+		atlases[i] = image.NewNRGBA(size)
+	}
+	for _, id := range ids {
+		s := m.Sprites[id]
+		atlas := atlases[indexOf(m.Atlases, s.Atlas)]
+		drawPadded(atlas, s.Rect, images[id])
+	}
+	return atlases, m, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i := range list {
+		if list[i] == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// drawPadded draws img into the rectangle r of the atlas and repeats its edge
+// pixels into the padding around it.
+func drawPadded(atlas *image.NRGBA, r game.Rectangle, img image.Image) {
+	b := img.Bounds()
+	dest := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+	draw.Draw(atlas, dest, img, b.Min, draw.Src)
+	for y := dest.Min.Y - Padding; y < dest.Max.Y+Padding; y++ {
+		for x := dest.Min.X - Padding; x < dest.Max.X+Padding; x++ {
+			if image.Pt(x, y).In(dest) {
+				continue
+			}
+			atlas.SetNRGBA(x, y, atlas.NRGBAAt(
+				clamp(x, dest.Min.X, dest.Max.X-1),
+				clamp(y, dest.Min.Y, dest.Max.Y-1),
+			))
+		}
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
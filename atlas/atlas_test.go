@@ -0,0 +1,149 @@
+package atlas
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/gonutz/ld36/game"
+)
+
+func filled(w, h int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestPackedSpritesDoNotOverlap(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	images := map[string]image.Image{
+		"caveman": filled(20, 30, red),
+		"rock":    filled(16, 16, color.NRGBA{0, 255, 0, 255}),
+		"gate":    filled(8, 25, color.NRGBA{0, 0, 255, 255}),
+		"pixel":   filled(1, 1, color.NRGBA{255, 255, 255, 255}),
+	}
+	atlases, m, err := Pack(images, "atlas", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atlases) != 1 || !reflect.DeepEqual(m.Atlases, []string{"atlas_0"}) {
+		t.Fatalf("want one atlas but have %v", m.Atlases)
+	}
+
+	var padded []image.Rectangle
+	for id, img := range images {
+		s, ok := m.Sprites[id]
+		if !ok {
+			t.Fatalf("%v is missing in the manifest", id)
+		}
+		r := image.Rect(s.Rect.X, s.Rect.Y, s.Rect.X+s.Rect.W, s.Rect.Y+s.Rect.H)
+		if r.Size() != img.Bounds().Size() {
+			t.Errorf("%v has size %v in the atlas", id, r.Size())
+		}
+		p := r.Inset(-Padding)
+		if !p.In(atlases[0].Bounds()) {
+			t.Errorf("%v at %v is not inside the atlas %v", id, p, atlases[0].Bounds())
+		}
+		for _, other := range padded {
+			if p.Overlaps(other) {
+				t.Errorf("%v at %v overlaps %v", id, p, other)
+			}
+		}
+		padded = append(padded, p)
+	}
+
+	caveman := m.Sprites["caveman"].Rect
+	for _, pt := range []image.Point{
+		{caveman.X, caveman.Y},
+		{caveman.X + caveman.W - 1, caveman.Y + caveman.H - 1},
+		// the edges are repeated into the padding
+		{caveman.X - Padding, caveman.Y - Padding},
+		{caveman.X + caveman.W + Padding - 1, caveman.Y + 5},
+	} {
+		if have := atlases[0].NRGBAAt(pt.X, pt.Y); have != red {
+			t.Errorf("want red at %v but have %v", pt, have)
+		}
+	}
+}
+
+func TestSpritesSpillIntoMoreAtlases(t *testing.T) {
+	images := map[string]image.Image{
+		"a": filled(28, 28, color.NRGBA{A: 255}),
+		"b": filled(28, 28, color.NRGBA{A: 255}),
+		"c": filled(28, 28, color.NRGBA{A: 255}),
+	}
+	atlases, m, err := Pack(images, "sprites", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atlases) != 1 {
+		t.Errorf("three 32x32 areas fit into 64x64 but there are %v atlases", len(atlases))
+	}
+
+	atlases, m, err = Pack(images, "sprites", 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sprites_0", "sprites_1", "sprites_2"}; !reflect.DeepEqual(m.Atlases, want) {
+		t.Errorf("want atlases %v but have %v", want, m.Atlases)
+	}
+	if atlases[0].Bounds() != image.Rect(0, 0, 32, 32) {
+		t.Errorf("atlas is not cut to its sprites, it is %v", atlases[0].Bounds())
+	}
+
+	if _, _, err := Pack(images, "sprites", 30); err == nil {
+		t.Error("sprite larger than the atlas was packed")
+	}
+}
+
+type drawRecorder struct {
+	game.Image
+	sources []game.Rectangle
+}
+
+func (r *drawRecorder) DrawRectAt(x, y int, source game.Rectangle) {
+	r.sources = append(r.sources, source)
+}
+
+func TestSubImageDrawsFromItsRect(t *testing.T) {
+	atlas := &drawRecorder{}
+	m := &Manifest{Sprites: map[string]Sprite{
+		"tiles": {Atlas: "atlas_0", Rect: game.Rectangle{X: 100, Y: 50, W: 48, H: 16}},
+	}}
+	loaded := map[string]bool{}
+	img := m.Load("tiles", func(id string) game.Image {
+		loaded[id] = true
+		return atlas
+	})
+	if !loaded["atlas_0"] || len(loaded) != 1 {
+		t.Fatalf("want only the atlas loaded but have %v", loaded)
+	}
+	if w, h := img.Size(); w != 48 || h != 16 {
+		t.Errorf("size is %vx%v", w, h)
+	}
+
+	img.DrawAt(0, 0)
+	img.DrawRectAt(0, 0, game.Rectangle{X: 16, W: 16, H: 16})
+	// sources are cut off at the sprite's edges
+	img.DrawRectAt(0, 0, game.Rectangle{X: 40, Y: -4, W: 16, H: 16})
+	want := []game.Rectangle{
+		{X: 100, Y: 50, W: 48, H: 16},
+		{X: 116, Y: 50, W: 16, H: 16},
+		{X: 140, Y: 50, W: 8, H: 12},
+	}
+	if !reflect.DeepEqual(atlas.sources, want) {
+		t.Errorf("want sources %v but have %v", want, atlas.sources)
+	}
+
+	var none *Manifest
+	none.Load("rock", func(id string) game.Image {
+		loaded[id] = true
+		return nil
+	})
+	if !loaded["rock"] {
+		t.Error("nil manifest did not load the image directly")
+	}
+}
@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// deviceLatency is the size of aplay's buffer in microseconds, a sound starts
+// about this long after Play is called.
+const deviceLatency = 70000
+
+// Device plays the output of a Mixer on the default sound device. It streams
+// the samples to the aplay program from ALSA's utilities, which comes with
+// practically every Linux desktop, so the game needs no C libraries for sound.
+type Device struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	mixer *Mixer
+	quit  chan bool
+	done  sync.WaitGroup
+}
+
+// OpenDevice starts playing the mixer's output. Close the device to stop.
+func OpenDevice(m *Mixer) (*Device, error) {
+	cmd := exec.Command("aplay",
+		"-q",
+		"-t", "raw",
+		"-f", "S16_LE",
+		"-c", "2",
+		"-r", strconv.Itoa(m.SampleRate()),
+		"--buffer-time="+strconv.Itoa(deviceLatency),
+	)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to aplay: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start aplay: %v", err)
+	}
+	d := &Device{cmd: cmd, stdin: stdin, mixer: m, quit: make(chan bool)}
+	d.done.Add(1)
+	go d.stream()
+	return d, nil
+}
+
+// stream writes the mixer's output to aplay until the device is closed. Writing
+// blocks while aplay's buffer is full, which keeps the mixer in step with the
+// sound card.
+func (d *Device) stream() {
+	defer d.done.Done()
+	buffer := make([]byte, 4*1024)
+	for {
+		select {
+		case <-d.quit:
+			return
+		default:
+		}
+		n, _ := d.mixer.Read(buffer)
+		if _, err := d.stdin.Write(buffer[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// Close stops playing and waits for aplay to exit.
+func (d *Device) Close() {
+	close(d.quit)
+	d.stdin.Close()
+	d.done.Wait()
+	d.cmd.Wait()
+}
@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	winmm                  = syscall.NewLazyDLL("winmm.dll")
+	waveOutOpen            = winmm.NewProc("waveOutOpen")
+	waveOutClose           = winmm.NewProc("waveOutClose")
+	waveOutPrepareHeader   = winmm.NewProc("waveOutPrepareHeader")
+	waveOutUnprepareHeader = winmm.NewProc("waveOutUnprepareHeader")
+	waveOutWrite           = winmm.NewProc("waveOutWrite")
+	waveOutReset           = winmm.NewProc("waveOutReset")
+)
+
+const (
+	waveMapper    = 0xFFFFFFFF
+	callbackNull  = 0
+	waveFormatPCM = 1
+	whdrDone      = 1
+)
+
+type waveFormatEx struct {
+	formatTag      uint16
+	channels       uint16
+	samplesPerSec  uint32
+	avgBytesPerSec uint32
+	blockAlign     uint16
+	bitsPerSample  uint16
+	size           uint16
+}
+
+type waveHdr struct {
+	data          *byte
+	bufferLength  uint32
+	bytesRecorded uint32
+	user          uintptr
+	flags         uint32
+	loops         uint32
+	next          uintptr
+	reserved      uintptr
+}
+
+const (
+	// deviceBuffers are queued at the device at all times, while the device
+	// plays one, the others are filled
+	deviceBuffers = 4
+	// deviceBufferFrames is about 23 ms at 44100 Hz, so a sound starts about
+	// 70 ms after Play is called
+	deviceBufferFrames = 1024
+)
+
+// Device plays the output of a Mixer on the default sound device through the
+// Windows waveOut API.
+type Device struct {
+	handle  uintptr
+	mixer   *Mixer
+	headers [deviceBuffers]waveHdr
+	buffers [deviceBuffers][]byte
+	quit    chan bool
+	done    sync.WaitGroup
+}
+
+// OpenDevice starts playing the mixer's output. Close the device to stop.
+func OpenDevice(m *Mixer) (*Device, error) {
+	format := waveFormatEx{
+		formatTag:      waveFormatPCM,
+		channels:       2,
+		samplesPerSec:  uint32(m.SampleRate()),
+		avgBytesPerSec: uint32(m.SampleRate() * 4),
+		blockAlign:     4,
+		bitsPerSample:  16,
+	}
+	d := &Device{mixer: m, quit: make(chan bool)}
+	if err := mmCall(waveOutOpen,
+		uintptr(unsafe.Pointer(&d.handle)),
+		waveMapper,
+		uintptr(unsafe.Pointer(&format)),
+		0, 0, callbackNull,
+	); err != nil {
+		return nil, fmt.Errorf("waveOutOpen failed: %v", err)
+	}
+
+	for i := range d.headers {
+		d.buffers[i] = make([]byte, 4*deviceBufferFrames)
+		d.headers[i].data = &d.buffers[i][0]
+		d.headers[i].bufferLength = uint32(len(d.buffers[i]))
+		if err := mmCall(waveOutPrepareHeader,
+			d.handle,
+			uintptr(unsafe.Pointer(&d.headers[i])),
+			unsafe.Sizeof(d.headers[i]),
+		); err != nil {
+			d.close(i)
+			return nil, fmt.Errorf("waveOutPrepareHeader failed: %v", err)
+		}
+		d.queue(i)
+	}
+
+	d.done.Add(1)
+	go d.stream()
+	return d, nil
+}
+
+// stream refills every buffer that the device finished playing until the
+// device is closed.
+func (d *Device) stream() {
+	defer d.done.Done()
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.quit:
+			return
+		case <-ticker.C:
+			for i := range d.headers {
+				// the driver sets the done flag from its own thread
+				if atomic.LoadUint32(&d.headers[i].flags)&whdrDone != 0 {
+					d.queue(i)
+				}
+			}
+		}
+	}
+}
+
+func (d *Device) queue(i int) {
+	d.mixer.Read(d.buffers[i])
+	flags := &d.headers[i].flags
+	atomic.StoreUint32(flags, atomic.LoadUint32(flags)&^whdrDone)
+	mmCall(waveOutWrite,
+		d.handle,
+		uintptr(unsafe.Pointer(&d.headers[i])),
+		unsafe.Sizeof(d.headers[i]),
+	)
+}
+
+// Close stops playing and releases the sound device.
+func (d *Device) Close() {
+	close(d.quit)
+	d.done.Wait()
+	d.close(len(d.headers))
+}
+
+// close releases the device and the first prepared headers.
+func (d *Device) close(prepared int) {
+	waveOutReset.Call(d.handle)
+	for i := 0; i < prepared; i++ {
+		waveOutUnprepareHeader.Call(
+			d.handle,
+			uintptr(unsafe.Pointer(&d.headers[i])),
+			unsafe.Sizeof(d.headers[i]),
+		)
+	}
+	waveOutClose.Call(d.handle)
+}
+
+// mmCall calls a waveOut function and turns its MMRESULT into an error.
+func mmCall(proc *syscall.LazyProc, args ...uintptr) error {
+	if err := proc.Find(); err != nil {
+		return err
+	}
+	result, _, _ := proc.Call(args...)
+	if result != 0 {
+		return errors.New(fmt.Sprint("MMRESULT ", result))
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/gonutz/ld36/game"
+)
+
+// DefaultSampleRate is the rate of CD audio, which all sounds in rsc use.
+const DefaultSampleRate = 44100
+
+// Mixer adds up all playing sounds into one stream of 16 bit stereo samples.
+// Sounds are started from the game while the stream is read by the sound
+// device, all methods are safe to call from different goroutines.
+type Mixer struct {
+	mu         sync.Mutex
+	sampleRate int
+	playbacks  []*Playback
+	volume     float32
+	muted      bool
+	// sum and samples are kept between calls so that the sound device's
+	// thread does not make garbage
+	sum     []float32
+	samples []int16
+}
+
+func NewMixer(sampleRate int) *Mixer {
+	return &Mixer{sampleRate: sampleRate, volume: 1}
+}
+
+// SetVolume scales the whole output, 0 is silent and 1 adds up the sounds as
+// they are.
+func (m *Mixer) SetVolume(volume float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.volume = clamp(volume, 0, 1)
+}
+
+// SetMuted silences the output while muted is true. Sounds keep playing so
+// they are at the same position as without muting.
+func (m *Mixer) SetMuted(muted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.muted = muted
+}
+
+// SampleRate is the number of frames per second in the mixed stream, each
+// frame is a left and a right sample.
+func (m *Mixer) SampleRate() int {
+	return m.sampleRate
+}
+
+// NewSound converts the wave to the mixer's format so mixing it does not need
+// any conversion. Mono waves are played on both channels and waves with a
+// different sample rate are resampled.
+func (m *Mixer) NewSound(w *Wave) *Sound {
+	return &Sound{
+		mixer:   m,
+		samples: resample(toStereo(w), w.SamplesPerSec, m.sampleRate),
+		volume:  1,
+	}
+}
+
+// Mix fills out with the next len(out)/2 frames of interleaved left and right
+// samples and advances all playing sounds by as many frames. Sounds that end
+// are removed, looping sounds start over right after their last frame. Paused
+// playbacks keep their position.
+func (m *Mixer) Mix(out []int16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mix(out)
+}
+
+// mix is Mix for a locked mixer.
+func (m *Mixer) mix(out []int16) {
+	frames := len(out) / 2
+	if cap(m.sum) < 2*frames {
+		m.sum = make([]float32, 2*frames)
+	}
+	sum := m.sum[:2*frames]
+	for i := range sum {
+		sum[i] = 0
+	}
+	playing := m.playbacks[:0]
+	for _, v := range m.playbacks {
+		if v.stopped {
+			continue
+		}
+		if v.paused {
+			playing = append(playing, v)
+			continue
+		}
+		samples := v.sound.samples
+		if len(samples) == 0 {
+			v.stopped = true
+			continue
+		}
+		left, right := v.sound.gains()
+		left *= v.volume * m.volume
+		right *= v.volume * m.volume
+		if m.muted {
+			left, right = 0, 0
+		}
+		done := false
+		for i := 0; i < frames; i++ {
+			if 2*v.pos >= len(samples) {
+				if !v.loop {
+					done = true
+					break
+				}
+				v.pos = 0
+			}
+			sum[2*i] += left * samples[2*v.pos]
+			sum[2*i+1] += right * samples[2*v.pos+1]
+			v.pos++
+		}
+		if !done && (v.loop || 2*v.pos < len(samples)) {
+			playing = append(playing, v)
+		} else {
+			v.stopped = true
+		}
+	}
+	for i := len(playing); i < len(m.playbacks); i++ {
+		m.playbacks[i] = nil
+	}
+	m.playbacks = playing
+
+	for i, s := range sum {
+		out[i] = toInt16(s)
+	}
+	if len(out)%2 == 1 {
+		out[len(out)-1] = 0
+	}
+}
+
+// Read implements io.Reader for sound devices that take a byte stream. It
+// mixes as many whole frames as fit into p as little endian 16 bit samples.
+func (m *Mixer) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(p) / 4 * 2
+	if cap(m.samples) < n {
+		m.samples = make([]int16, n)
+	}
+	samples := m.samples[:n]
+	m.mix(samples)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(p[2*i:], uint16(s))
+	}
+	return 2 * len(samples), nil
+}
+
+// Sound is a wave that can be played on its Mixer any number of times at
+// once. It implements game.Sound.
+type Sound struct {
+	mixer *Mixer
+	// samples are interleaved left and right values from -1 to 1 at the
+	// mixer's sample rate
+	samples []float32
+	volume  float32
+	pan     float32
+}
+
+// Play starts a new playback of the sound from the beginning, playbacks that
+// already run continue.
+func (s *Sound) Play() game.SoundInstance {
+	return s.start(false)
+}
+
+// PlayLooping starts the sound and plays it over and over until it is stopped.
+func (s *Sound) PlayLooping() game.SoundInstance {
+	return s.start(true)
+}
+
+func (s *Sound) start(loop bool) *Playback {
+	s.mixer.mu.Lock()
+	defer s.mixer.mu.Unlock()
+	p := &Playback{sound: s, loop: loop, volume: 1}
+	s.mixer.playbacks = append(s.mixer.playbacks, p)
+	return p
+}
+
+// Stop ends all playbacks of the sound.
+func (s *Sound) Stop() {
+	s.mixer.mu.Lock()
+	defer s.mixer.mu.Unlock()
+	playing := s.mixer.playbacks[:0]
+	for _, v := range s.mixer.playbacks {
+		if v.sound == s {
+			v.stopped = true
+		} else {
+			playing = append(playing, v)
+		}
+	}
+	for i := len(playing); i < len(s.mixer.playbacks); i++ {
+		s.mixer.playbacks[i] = nil
+	}
+	s.mixer.playbacks = playing
+}
+
+// IsPlaying reports whether any playback of the sound has not ended yet.
+func (s *Sound) IsPlaying() bool {
+	s.mixer.mu.Lock()
+	defer s.mixer.mu.Unlock()
+	for _, v := range s.mixer.playbacks {
+		if v.sound == s && !v.stopped && !v.paused {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVolume scales all samples of the sound, 0 is silent and 1 is the volume
+// of the wave. It applies to playbacks that already started.
+func (s *Sound) SetVolume(volume float32) {
+	s.mixer.mu.Lock()
+	defer s.mixer.mu.Unlock()
+	s.volume = clamp(volume, 0, 1)
+}
+
+// SetPan moves the sound between the left (-1) and right (1) speaker, 0 plays
+// both channels at full volume. It applies to playbacks that already started.
+func (s *Sound) SetPan(pan float32) {
+	s.mixer.mu.Lock()
+	defer s.mixer.mu.Unlock()
+	s.pan = clamp(pan, -1, 1)
+}
+
+// gains are the factors for the left and right channel, the mixer must be
+// locked.
+func (s *Sound) gains() (left, right float32) {
+	left, right = s.volume, s.volume
+	if s.pan > 0 {
+		left *= 1 - s.pan
+	} else {
+		right *= 1 + s.pan
+	}
+	return
+}
+
+// Playback is one playback of a Sound, it implements game.SoundInstance.
+type Playback struct {
+	sound *Sound
+	// pos is the index of the next frame, i.e. pair of left and right samples
+	pos     int
+	loop    bool
+	volume  float32
+	paused  bool
+	stopped bool
+}
+
+// Stop ends the playback, it cannot be resumed.
+func (p *Playback) Stop() {
+	p.sound.mixer.mu.Lock()
+	defer p.sound.mixer.mu.Unlock()
+	p.stopped = true
+}
+
+// Pause holds the playback at its current position until Resume is called.
+func (p *Playback) Pause() {
+	p.sound.mixer.mu.Lock()
+	defer p.sound.mixer.mu.Unlock()
+	p.paused = true
+}
+
+// Resume continues a paused playback.
+func (p *Playback) Resume() {
+	p.sound.mixer.mu.Lock()
+	defer p.sound.mixer.mu.Unlock()
+	p.paused = false
+}
+
+// SetVolume scales the playback in addition to the sound's volume.
+func (p *Playback) SetVolume(volume float32) {
+	p.sound.mixer.mu.Lock()
+	defer p.sound.mixer.mu.Unlock()
+	p.volume = clamp(volume, 0, 1)
+}
+
+// IsPlaying reports whether the playback is neither paused, stopped nor at its
+// end.
+func (p *Playback) IsPlaying() bool {
+	p.sound.mixer.mu.Lock()
+	defer p.sound.mixer.mu.Unlock()
+	return !p.paused && !p.stopped
+}
+
+// toStereo converts the wave's samples to interleaved left and right values
+// from -1 to 1.
+func toStereo(w *Wave) []float32 {
+	bytesPerSample := w.BitsPerSample / 8
+	count := len(w.Data) / bytesPerSample / w.Channels
+	samples := make([]float32, 2*count)
+	for i := 0; i < count; i++ {
+		for c := 0; c < 2; c++ {
+			src := i*w.Channels + c%w.Channels
+			var v float32
+			if bytesPerSample == 1 {
+				v = (float32(w.Data[src]) - 128) / 128
+			} else {
+				v = float32(int16(binary.LittleEndian.Uint16(w.Data[2*src:]))) / 32768
+			}
+			samples[2*i+c] = v
+		}
+	}
+	return samples
+}
+
+// resample converts interleaved stereo samples from one rate to another with
+// linear interpolation.
+func resample(samples []float32, from, to int) []float32 {
+	if from == to || from <= 0 || len(samples) == 0 {
+		return samples
+	}
+	count := len(samples) / 2
+	newCount := int(int64(count) * int64(to) / int64(from))
+	resampled := make([]float32, 2*newCount)
+	for i := 0; i < newCount; i++ {
+		pos := float64(i) * float64(from) / float64(to)
+		j := int(pos)
+		t := float32(pos - float64(j))
+		next := j + 1
+		if next >= count {
+			next = count - 1
+		}
+		for c := 0; c < 2; c++ {
+			a, b := samples[2*j+c], samples[2*next+c]
+			resampled[2*i+c] = a + t*(b-a)
+		}
+	}
+	return resampled
+}
+
+func toInt16(v float32) int16 {
+	s := math.Floor(float64(v)*32768 + 0.5)
+	if s > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if s < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(s)
+}
+
+func clamp(v, min, max float32) float32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,224 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestLoopingRestartsAfterLastFrame(t *testing.T) {
+	m := NewMixer(100)
+	s := m.NewSound(wave16(100, 1, 1000, 2000, 3000))
+	s.PlayLooping()
+
+	out := make([]int16, 2*8)
+	m.Mix(out)
+	want := stereo(1000, 2000, 3000, 1000, 2000, 3000, 1000, 2000)
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, out)
+	}
+	m.Mix(out[:4])
+	if want := stereo(3000, 1000); !reflect.DeepEqual(out[:4], want) {
+		t.Errorf("the loop does not continue where the last mix stopped, want %v but have %v", want, out[:4])
+	}
+}
+
+func TestPlaybacksAreAddedUntilTheyEnd(t *testing.T) {
+	m := NewMixer(100)
+	s := m.NewSound(wave16(100, 1, 1000, 2000, 3000))
+	s.Play()
+	m.Mix(make([]int16, 2))
+	s.Play()
+
+	out := make([]int16, 2*4)
+	m.Mix(out)
+	if want := stereo(3000, 5000, 3000, 0); !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+	if s.IsPlaying() {
+		t.Error("sound is still playing after its end")
+	}
+}
+
+func TestReadDoesNotAllocate(t *testing.T) {
+	m := NewMixer(100)
+	m.NewSound(wave16(100, 1, 1000, 2000, 3000)).PlayLooping()
+	p := make([]byte, 4*64)
+	m.Read(p)
+	if n := testing.AllocsPerRun(10, func() { m.Read(p) }); n != 0 {
+		t.Errorf("Read allocates %v times", n)
+	}
+	// 12 reads of 64 frames end after a whole loop, the scratch buffers do
+	// not keep the samples of the last read
+	m.Read(p[:8])
+	have := make([]int16, 4)
+	binary.Read(bytes.NewReader(p[:8]), binary.LittleEndian, have)
+	if want := stereo(1000, 2000); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v but have %v", want, have)
+	}
+}
+
+func TestStopEndsAllPlaybacks(t *testing.T) {
+	m := NewMixer(100)
+	s := m.NewSound(wave16(100, 1, 1000, 2000, 3000))
+	other := m.NewSound(wave16(100, 1, 5))
+	s.PlayLooping()
+	s.Play()
+	other.PlayLooping()
+	s.Stop()
+
+	if s.IsPlaying() {
+		t.Error("sound is still playing after Stop")
+	}
+	if !other.IsPlaying() {
+		t.Error("Stop ended another sound")
+	}
+	out := make([]int16, 2*2)
+	m.Mix(out)
+	if want := stereo(5, 5); !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+}
+
+func TestVolumeAndPanScaleChannels(t *testing.T) {
+	m := NewMixer(100)
+	s := m.NewSound(wave16(100, 2, 1000, -1000))
+	s.PlayLooping()
+	s.SetVolume(0.5)
+	s.SetPan(0.5)
+
+	out := make([]int16, 2)
+	m.Mix(out)
+	if want := []int16{250, -500}; !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+}
+
+func TestLoudMixesAreClipped(t *testing.T) {
+	m := NewMixer(100)
+	m.NewSound(wave16(100, 2, 30000, -30000)).Play()
+	m.NewSound(wave16(100, 2, 30000, -30000)).Play()
+
+	out := make([]int16, 2)
+	m.Mix(out)
+	if want := []int16{32767, -32768}; !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+}
+
+func TestWavesAreConvertedToMixerRate(t *testing.T) {
+	m := NewMixer(200)
+	s := m.NewSound(&Wave{
+		Channels:      1,
+		SamplesPerSec: 100,
+		BitsPerSample: 8,
+		Data:          []byte{128, 192},
+	})
+	s.Play()
+
+	out := make([]int16, 2*5)
+	m.Mix(out)
+	if want := stereo(0, 8192, 16384, 16384, 0); !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+}
+
+func TestWrittenWavIsDecoded(t *testing.T) {
+	samples := []int16{1, -2, 300, -400, 32767, -32768}
+	var buf bytes.Buffer
+	if err := WriteWav(&buf, 22050, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := DecodeWav(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Channels != 2 || w.SamplesPerSec != 22050 || w.BitsPerSample != 16 {
+		t.Errorf("wrong format: %v channels, %v Hz, %v bits",
+			w.Channels, w.SamplesPerSec, w.BitsPerSample)
+	}
+	if want := wave16(22050, 2, samples...).Data; !bytes.Equal(w.Data, want) {
+		t.Errorf("want data\n%v\nbut have\n%v", want, w.Data)
+	}
+}
+
+func wave16(rate, channels int, samples ...int16) *Wave {
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	return &Wave{
+		Channels:      channels,
+		SamplesPerSec: rate,
+		BitsPerSample: 16,
+		Data:          data,
+	}
+}
+
+// stereo returns the samples for both the left and right channel.
+func stereo(samples ...int16) []int16 {
+	var both []int16
+	for _, s := range samples {
+		both = append(both, s, s)
+	}
+	return both
+}
+
+func TestPlaybacksAreControlledSeparately(t *testing.T) {
+	m := NewMixer(100)
+	s := m.NewSound(wave16(100, 1, 1000, 2000, 3000))
+	a := s.PlayLooping()
+	b := s.PlayLooping()
+	b.SetVolume(0.5)
+
+	out := make([]int16, 2)
+	m.Mix(out)
+	if want := stereo(1500); !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+
+	a.Pause()
+	m.Mix(out)
+	if want := stereo(1000); !reflect.DeepEqual(out, want) {
+		t.Errorf("paused playback was mixed, want %v but have %v", want, out)
+	}
+	if a.IsPlaying() || !b.IsPlaying() {
+		t.Errorf("want only the second playback playing but have %v and %v",
+			a.IsPlaying(), b.IsPlaying())
+	}
+
+	a.Resume()
+	b.Stop()
+	m.Mix(out)
+	if want := stereo(2000); !reflect.DeepEqual(out, want) {
+		t.Errorf("resumed playback did not continue where it was paused, want %v but have %v", want, out)
+	}
+	b.Resume()
+	if b.IsPlaying() {
+		t.Error("stopped playback was resumed")
+	}
+}
+
+func TestMasterVolumeAndMute(t *testing.T) {
+	m := NewMixer(100)
+	m.NewSound(wave16(100, 1, 1000, 2000, 3000)).Play()
+	m.SetVolume(0.5)
+
+	out := make([]int16, 2)
+	m.Mix(out)
+	if want := stereo(500); !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v but have %v", want, out)
+	}
+	m.SetMuted(true)
+	m.Mix(out)
+	if want := stereo(0); !reflect.DeepEqual(out, want) {
+		t.Errorf("want %v while muted but have %v", want, out)
+	}
+	m.SetMuted(false)
+	m.Mix(out)
+	if want := stereo(1500); !reflect.DeepEqual(out, want) {
+		t.Errorf("muting did not keep the sound going, want %v but have %v", want, out)
+	}
+}
@@ -0,0 +1,115 @@
+// Package audio mixes sounds in software into a 16 bit stereo PCM stream. The
+// stream can be played on a sound device or written to a WAV file, which makes
+// it possible to check the game's audio without any sound hardware.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Wave is the content of an uncompressed PCM WAV file. Data holds the samples
+// as they are stored in the file, 8 bit samples are unsigned and 16 bit samples
+// are signed little endian. Channels are interleaved.
+type Wave struct {
+	Channels      int
+	SamplesPerSec int
+	BitsPerSample int
+	Data          []byte
+}
+
+// DecodeWav reads an uncompressed PCM RIFF/WAVE file.
+func DecodeWav(data []byte) (*Wave, error) {
+	if len(data) < 12 ||
+		!bytes.Equal(data[0:4], []byte("RIFF")) ||
+		!bytes.Equal(data[8:12], []byte("WAVE")) {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	var w Wave
+	haveFormat := false
+	rest := data[12:]
+	for len(rest) >= 8 {
+		id := string(rest[0:4])
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		rest = rest[8:]
+		if size > len(rest) {
+			// some writers put a wrong size into the last chunk
+			size = len(rest)
+		}
+		chunk := rest[:size]
+
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, errors.New("format chunk too short")
+			}
+			if format := binary.LittleEndian.Uint16(chunk[0:2]); format != 1 {
+				return nil, fmt.Errorf("unsupported wave format %v, only PCM is supported", format)
+			}
+			w.Channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			w.SamplesPerSec = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			w.BitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:16]))
+			haveFormat = true
+		case "data":
+			w.Data = chunk
+		}
+
+		// chunks are padded to an even size
+		if size%2 == 1 && size < len(rest) {
+			size++
+		}
+		rest = rest[size:]
+	}
+
+	if !haveFormat {
+		return nil, errors.New("missing format chunk")
+	}
+	if w.Data == nil {
+		return nil, errors.New("missing data chunk")
+	}
+	if w.BitsPerSample != 8 && w.BitsPerSample != 16 {
+		return nil, fmt.Errorf("unsupported bits per sample: %v", w.BitsPerSample)
+	}
+	if w.Channels != 1 && w.Channels != 2 {
+		return nil, fmt.Errorf("unsupported channel count: %v", w.Channels)
+	}
+	return &w, nil
+}
+
+// WriteWav writes interleaved 16 bit stereo samples, as produced by Mixer.Mix,
+// as a PCM WAV file.
+func WriteWav(w io.Writer, sampleRate int, samples []int16) error {
+	const (
+		channels       = 2
+		bytesPerSample = 2
+	)
+	dataSize := len(samples) * bytesPerSample
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+dataSize))
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], channels)
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*channels*bytesPerSample))
+	binary.LittleEndian.PutUint16(header[32:], channels*bytesPerSample)
+	binary.LittleEndian.PutUint16(header[34:], 8*bytesPerSample)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(dataSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	data := make([]byte, dataSize)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(sample))
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,64 @@
+// Package batch collects textured quads and hands them to a rendering backend
+// in groups that share a texture, so the backend needs one draw call per
+// texture change instead of one per image.
+package batch
+
+// Vertex is a corner of a quad in screen pixels with its texture coordinates
+// from 0 to 1 and its color in ARGB with 8 bits per component.
+type Vertex struct {
+	X, Y, U, V float32
+	Color      uint32
+}
+
+// Quad has its corners in the order top-left, top-right, bottom-left,
+// bottom-right, which is a triangle strip of two triangles.
+type Quad [4]Vertex
+
+// Triangles appends the quad's two triangles to a triangle list.
+func (q *Quad) Triangles(list []Vertex) []Vertex {
+	return append(list, q[0], q[1], q[2], q[2], q[1], q[3])
+}
+
+// Batch collects quads until the texture changes or Flush is called. Textures
+// can be any comparable values that identify them in the backend.
+type Batch struct {
+	draw      func(texture interface{}, quads []Quad)
+	texture   interface{}
+	quads     []Quad
+	drawCalls int
+}
+
+// New creates a batch that calls draw with every group of quads. The quads
+// passed to draw are only valid during the call.
+func New(draw func(texture interface{}, quads []Quad)) *Batch {
+	return &Batch{draw: draw}
+}
+
+// Add queues a quad. If its texture differs from the queued quads' texture,
+// these are drawn first.
+func (b *Batch) Add(texture interface{}, q Quad) {
+	if len(b.quads) > 0 && texture != b.texture {
+		b.Flush()
+	}
+	b.texture = texture
+	b.quads = append(b.quads, q)
+}
+
+// Flush draws all queued quads. Call it at the end of a frame and before
+// changing any render state that affects the queued quads.
+func (b *Batch) Flush() {
+	if len(b.quads) == 0 {
+		return
+	}
+	b.draw(b.texture, b.quads)
+	b.drawCalls++
+	b.quads = b.quads[:0]
+}
+
+// DrawCalls returns the number of groups drawn since the last call and starts
+// counting from 0 again, e.g. for statistics per frame.
+func (b *Batch) DrawCalls() int {
+	n := b.drawCalls
+	b.drawCalls = 0
+	return n
+}
@@ -0,0 +1,50 @@
+package batch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuadsAreGroupedUntilTheTextureChanges(t *testing.T) {
+	var draws []string
+	b := New(func(texture interface{}, quads []Quad) {
+		for range quads {
+			draws = append(draws, texture.(string))
+		}
+		draws = append(draws, "|")
+	})
+
+	for _, texture := range []string{"tiles", "tiles", "tiles", "rock", "tiles", "tiles"} {
+		b.Add(texture, Quad{})
+	}
+	want := []string{"tiles", "tiles", "tiles", "|", "rock", "|"}
+	if !reflect.DeepEqual(draws, want) {
+		t.Errorf("before flushing want %v but have %v", want, draws)
+	}
+
+	b.Flush()
+	b.Flush()
+	want = append(want, "tiles", "tiles", "|")
+	if !reflect.DeepEqual(draws, want) {
+		t.Errorf("after flushing want %v but have %v", want, draws)
+	}
+	if n := b.DrawCalls(); n != 3 {
+		t.Errorf("want 3 draw calls but have %v", n)
+	}
+	if n := b.DrawCalls(); n != 0 {
+		t.Errorf("draw calls are not reset, have %v", n)
+	}
+}
+
+func TestQuadsAreSplitIntoTriangles(t *testing.T) {
+	q := Quad{{X: 0}, {X: 1}, {X: 2}, {X: 3}}
+	var list []Vertex
+	list = q.Triangles(list)
+	var xs []float32
+	for _, v := range list {
+		xs = append(xs, v.X)
+	}
+	if want := []float32{0, 1, 2, 2, 1, 3}; !reflect.DeepEqual(xs, want) {
+		t.Errorf("want corners %v but have %v", want, xs)
+	}
+}
@@ -0,0 +1,5 @@
+#!/bin/sh
+set -e
+
+go run ./cmd/make_assets -out bin/blob -embed rsc/embedded
+go build -o bin/reinventing_the_wheel .
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/ld36/game"
+)
+
+// cacheFileName is the name of the cache in the PNG folder.
+const cacheFileName = "make_assets.json"
+
+// cache remembers what the last run built.
+type cache struct {
+	// Hashes maps a step's name to the hash of its sources when it last ran.
+	Hashes map[string]string
+	// Info is the game info of the last run. Skipped steps keep their parts
+	// of it.
+	Info game.Info
+}
+
+// loadCache returns an empty cache if there is none or it cannot be read, then
+// everything is rebuilt.
+func loadCache(path string) cache {
+	c := cache{Hashes: map[string]string{}}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Hashes == nil {
+		return cache{Hashes: map[string]string{}}
+	}
+	return c
+}
+
+func (c cache) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+// step makes its output files from its sources.
+type step struct {
+	name    string
+	sources []string
+	outputs []string
+	// settings are hashed with the sources, e.g. the constants that a
+	// generated image depends on
+	settings string
+	run      func(info *game.Info)
+}
+
+// isUpToDate reports whether the step's outputs all exist and were made from
+// sources with the given hash.
+func (c cache) isUpToDate(s step, hash string) bool {
+	if c.Hashes[s.name] != hash {
+		return false
+	}
+	for _, path := range s.outputs {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// hashFiles hashes the settings and the names and contents of the files.
+func hashFiles(paths []string, settings string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", settings)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%q %d\n", filepath.Base(path), len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// runSteps runs every step whose sources changed since the last run, or all
+// steps if force is true. It returns the combined info of all steps.
+func runSteps(c *cache, steps []step, force bool) (game.Info, error) {
+	info := c.Info
+	for _, s := range steps {
+		hash, err := hashFiles(s.sources, s.settings)
+		if err != nil {
+			return info, fmt.Errorf("%v: %v", s.name, err)
+		}
+		if !force && c.isUpToDate(s, hash) {
+			fmt.Println("up to date:", s.name)
+			continue
+		}
+		fmt.Println("building:", s.name)
+		s.run(&info)
+		c.Hashes[s.name] = hash
+	}
+	c.Info = info
+	return info, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonutz/ld36/game"
+)
+
+func TestStepsOnlyRunWhenTheirSourcesChange(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("rock.xcf", "rock v1")
+	runs := 0
+	steps := []step{{
+		name:    "rock",
+		sources: []string{filepath.Join(dir, "rock.xcf")},
+		outputs: []string{filepath.Join(dir, "rock.png")},
+		run: func(info *game.Info) {
+			runs++
+			write("rock.png", "png")
+			info.RockHitBox.W = 146
+		},
+	}}
+	build := func() game.Info {
+		c := loadCache(filepath.Join(dir, cacheFileName))
+		info, err := runSteps(&c, steps, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.save(filepath.Join(dir, cacheFileName)); err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	build()
+	info := build()
+	if runs != 1 {
+		t.Errorf("step ran %v times for the same source", runs)
+	}
+	if info.RockHitBox.W != 146 {
+		t.Errorf("skipped step lost its info %+v", info)
+	}
+
+	write("rock.xcf", "rock v2")
+	build()
+	if runs != 2 {
+		t.Error("step did not run after its source changed")
+	}
+
+	write("rock.png", "edited")
+	build()
+	if runs != 2 {
+		t.Error("changing an output made the step run")
+	}
+}
+
+func TestMissingOutputsAreRebuilt(t *testing.T) {
+	dir := t.TempDir()
+	c := loadCache(filepath.Join(dir, cacheFileName))
+	runs := 0
+	s := step{
+		name:    "pixel",
+		outputs: []string{filepath.Join(dir, "pixel.png")},
+		run:     func(*game.Info) { runs++ },
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := runSteps(&c, []step{s}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("want 2 runs without an output but have %v", runs)
+	}
+}
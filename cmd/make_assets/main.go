@@ -0,0 +1,489 @@
+// make_assets turns the sources in the rsc folder, GIMP .xcf files and
+// original PNGs, into the images that the game loads, writes the info.json
+// that describes them and packs all game files into a blob. All images are
+// packed into atlases, see package atlas, and only these go into the blob.
+//
+//	go run ./cmd/make_assets -rsc rsc -out bin/blob
+//
+// The generated PNGs and info.json go to the -png folder which is the rsc
+// folder if it is not set, that way the game can read all its files from
+// there too. With -embed, the blob is also written into the given embedded
+// package, see rsc/embedded.
+//
+//	go run ./cmd/make_assets -embed rsc/embedded
+//
+// Outputs are only rebuilt when the contents of their sources changed since
+// the last run. The hashes are kept in make_assets.json in the -png folder,
+// -force rebuilds everything.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/blob"
+	"github.com/gonutz/ld36/atlas"
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/xcf"
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	rscDir   = flag.String("rsc", "rsc", "folder with the source files")
+	pngDir   = flag.String("png", "", "folder for the generated PNGs and info.json, the -rsc folder if empty")
+	outPath  = flag.String("out", filepath.Join("bin", "blob"), "blob file to write")
+	embedDir = flag.String("embed", "", "embedded package folder to also write the blob to, e.g. rsc/embedded")
+	force    = flag.Bool("force", false, "rebuild all outputs even if their sources did not change")
+)
+
+// atlasSize is the largest atlas width and height, all graphics cards that
+// run Direct3D 9 support textures of this size.
+const atlasSize = 2048
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *pngDir == "" {
+		*pngDir = *rscDir
+	}
+	check(os.MkdirAll(*pngDir, 0777))
+	check(os.MkdirAll(filepath.Dir(*outPath), 0777))
+
+	cachePath := filepath.Join(*pngDir, cacheFileName)
+	c := loadCache(cachePath)
+	steps := imageSteps()
+	info, err := runSteps(&c, steps, *force)
+	check(err)
+
+	sprites := []string{source("controls.png"), source("win_screen.png")}
+	for _, s := range steps {
+		sprites = append(sprites, s.outputs...)
+	}
+	_, err = runSteps(&c, []step{{
+		name:     "atlas",
+		sources:  sprites,
+		outputs:  atlasFiles(),
+		settings: fmt.Sprint(atlasSize, atlas.Padding),
+		run: func(*game.Info) {
+			packAtlases(sprites)
+		},
+	}}, *force)
+	check(err)
+
+	blobFiles := []string{
+		source("back_music.wav"),
+		source("cloud.wav"),
+	}
+	blobFiles = append(blobFiles, atlasFiles()...)
+	levels, err := filepath.Glob(source("*.tmx"))
+	check(err)
+	info.LevelCount = len(levels)
+	blobFiles = append(blobFiles, levels...)
+	// levels may reference external tilesets
+	tilesets, err := filepath.Glob(source("*.tsx"))
+	check(err)
+	blobFiles = append(blobFiles, tilesets...)
+
+	infoBuffer := bytes.NewBuffer(nil)
+	check(json.NewEncoder(infoBuffer).Encode(info))
+	check(ioutil.WriteFile(generated("info.json"), infoBuffer.Bytes(), 0666))
+	blobFiles = append(blobFiles, generated("info.json"))
+
+	blobStep := step{
+		name:    "blob",
+		sources: blobFiles,
+		outputs: []string{*outPath},
+		run: func(*game.Info) {
+			writeBlob(blobFiles)
+		},
+	}
+	if *embedDir != "" {
+		blobStep.outputs = append(blobStep.outputs,
+			filepath.Join(*embedDir, "blob"),
+			filepath.Join(*embedDir, "blob.go"),
+		)
+	}
+	_, err = runSteps(&c, []step{blobStep}, *force)
+	check(err)
+
+	check(c.save(cachePath))
+}
+
+func imageSteps() []step {
+	return []step{
+		{
+			name:    "caveman",
+			sources: []string{source("caveman.xcf")},
+			outputs: generatedPngs(
+				"caveman_stand_left",
+				"caveman_push_left_0",
+				"caveman_push_left_1",
+				"caveman_push_left_2",
+				"caveman_push_left_3",
+				"caveman_walk_left_0",
+				"caveman_walk_left_1",
+				"caveman_walk_left_2",
+				"caveman_walk_left_3",
+				"caveman_fall_left",
+			),
+			run: func(info *game.Info) {
+				caveman := loadXCF("caveman")
+				compile(caveman, "stand left", "caveman_stand_left")
+				compile(caveman, "push left 1", "caveman_push_left_0")
+				compile(caveman, "push left 2", "caveman_push_left_1")
+				compile(caveman, "push left 3", "caveman_push_left_2")
+				compile(caveman, "push left 4", "caveman_push_left_3")
+				compile(caveman, "walk left 1", "caveman_walk_left_0")
+				compile(caveman, "walk left 2", "caveman_walk_left_1")
+				compile(caveman, "walk left 3", "caveman_walk_left_2")
+				compile(caveman, "walk left 4", "caveman_walk_left_3")
+				compile(caveman, "fall left", "caveman_fall_left")
+				info.CavemanHitBox = scaleRect(
+					extractCollisionRect(caveman.GetLayerByName("collision")), 0.25,
+				)
+			},
+		},
+		{
+			name:    "rock",
+			sources: []string{source("rock.xcf")},
+			outputs: generatedPngs("rock"),
+			run: func(info *game.Info) {
+				rocks := loadXCF("rock")
+				compile(rocks, "rock", "rock")
+				info.RockHitBox = scaleRect(
+					extractCollisionRect(rocks.GetLayerByName("collision")), 0.25,
+				)
+			},
+		},
+		{
+			name:    "gate",
+			sources: []string{source("gate.xcf")},
+			outputs: generatedPngs("gate_a", "gate_b"),
+			run: func(info *game.Info) {
+				gates := loadXCF("gate")
+				compile(gates, "a", "gate_a")
+				compile(gates, "b", "gate_b")
+				info.GateWidth = loadPng(generated("gate_a.png")).Bounds().Dx()
+			},
+		},
+		{
+			name:    "gate_cloud",
+			sources: []string{source("gate_cloud_original.png")},
+			outputs: generatedPngs("gate_cloud"),
+			run: func(*game.Info) {
+				savePng(
+					swapRedBlue(makeTransparentAreasBlack(
+						loadPng(source("gate_cloud_original.png")),
+					)),
+					"gate_cloud",
+				)
+			},
+		},
+		{
+			name:    "tiles",
+			sources: []string{source("tiles.xcf")},
+			outputs: generatedPngs("tiles"),
+			run: func(*game.Info) {
+				// this is for editing the map in Tiled, it looks better with
+				// its original colors
+				//savePng(tileSheet(), "tiles") // for editing in Tiled
+				savePng(swapRedBlue(tileSheet()), "tiles") // for the final game
+			},
+		},
+		{
+			name:    "font",
+			outputs: generatedPngs("font"),
+			settings: fmt.Sprint(
+				game.FontFirstChar, game.FontLastChar, game.FontColumns,
+				game.FontGlyphWidth, game.FontGlyphHeight,
+			),
+			run: func(*game.Info) {
+				savePng(swapRedBlue(renderFont()), "font")
+			},
+		},
+		{
+			name:    "pixel",
+			outputs: generatedPngs("pixel"),
+			run: func(*game.Info) {
+				// a white pixel is scaled and tinted to draw solid rectangles
+				pixel := image.NewRGBA(image.Rect(0, 0, 1, 1))
+				pixel.Set(0, 0, color.White)
+				savePng(pixel, "pixel")
+			},
+		},
+	}
+}
+
+// atlasFiles returns the manifest and the atlases that it names. The manifest
+// is only read if it exists, otherwise the atlases are not known yet.
+func atlasFiles() []string {
+	files := []string{generated(atlas.ManifestFile)}
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return files
+	}
+	m, err := atlas.ReadManifest(data)
+	check(err)
+	for _, name := range m.Atlases {
+		files = append(files, generated(name+".png"))
+	}
+	return files
+}
+
+// packAtlases packs the PNG files into atlases and writes these and their
+// manifest. The sprites are named after the files without extension.
+func packAtlases(paths []string) {
+	images := make(map[string]image.Image)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		images[name[:len(name)-len(filepath.Ext(name))]] = loadPng(path)
+	}
+	atlases, m, err := atlas.Pack(images, "atlas", atlasSize)
+	check(err)
+	for i, img := range atlases {
+		savePng(img, m.Atlases[i])
+	}
+	data, err := json.Marshal(m)
+	check(err)
+	check(ioutil.WriteFile(generated(atlas.ManifestFile), data, 0666))
+}
+
+// source returns the path of a file in the rsc folder.
+func source(name string) string {
+	return filepath.Join(*rscDir, name)
+}
+
+// generated returns the path of a file in the PNG folder.
+func generated(name string) string {
+	return filepath.Join(*pngDir, name)
+}
+
+func generatedPngs(names ...string) []string {
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = generated(name + ".png")
+	}
+	return paths
+}
+
+func writeBlob(files []string) {
+	output := blob.New()
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		check(err)
+		output.Append(filepath.Base(path), data)
+	}
+
+	var blobData bytes.Buffer
+	check(output.Write(&blobData))
+	check(ioutil.WriteFile(*outPath, blobData.Bytes(), 0666))
+
+	if *embedDir != "" {
+		writeEmbeddedBlob(*embedDir, blobData.Bytes())
+	}
+}
+
+// embeddedBlobGo is written to the embedded package to compile the blob into
+// the executable.
+const embeddedBlobGo = `// Code generated by make_assets -embed; DO NOT EDIT.
+
+package embedded
+
+import _ "embed"
+
+//go:embed blob
+var blob []byte
+
+func init() {
+	Blob = blob
+}
+`
+
+// writeEmbeddedBlob puts the blob into the embedded package, see its
+// documentation.
+func writeEmbeddedBlob(dir string, data []byte) {
+	check(ioutil.WriteFile(filepath.Join(dir, "blob"), data, 0666))
+	check(ioutil.WriteFile(filepath.Join(dir, "blob.go"), []byte(embeddedBlobGo), 0666))
+}
+
+// tileSheet puts all tiles, one per layer, into a square grid.
+func tileSheet() image.Image {
+	tiles := loadXCF("tiles")
+	tileW, tileH := tiles.Layers[0].Bounds().Dx(), tiles.Layers[0].Bounds().Dy()
+	tileCount := len(tiles.Layers)
+	sheetSize := int(math.Ceil(math.Sqrt(float64(tileCount))) + 0.5)
+	sheet := image.NewRGBA(image.Rect(0, 0, sheetSize*tileW, sheetSize*tileH))
+	drawnTiles := 0
+	for _, layer := range tiles.Layers {
+		x := (drawnTiles % sheetSize) * tileW
+		y := (drawnTiles / sheetSize) * tileH
+		r := image.Rect(x, y, x+tileW, y+tileH)
+		draw.Draw(sheet, r, layer, layer.Bounds().Min, draw.Src)
+		drawnTiles++
+	}
+	return sheet
+}
+
+// renderFont draws the characters of a 7x13 pixel font into a grid, see
+// game.FontFirstChar. The glyphs are white with a black shadow so they can be
+// tinted in the game.
+func renderFont() image.Image {
+	const count = game.FontLastChar - game.FontFirstChar + 1
+	rows := int(count+game.FontColumns-1) / game.FontColumns
+	img := image.NewRGBA(image.Rect(
+		0, 0, game.FontColumns*game.FontGlyphWidth, rows*game.FontGlyphHeight,
+	))
+	face := basicfont.Face7x13
+	d := font.Drawer{Dst: img, Face: face}
+	for i := 0; i < count; i++ {
+		char := string(rune(game.FontFirstChar + i))
+		x := i % game.FontColumns * game.FontGlyphWidth
+		y := i/game.FontColumns*game.FontGlyphHeight + face.Ascent
+		d.Src = image.NewUniform(color.Black)
+		d.Dot = fixed.P(x+1, y+1)
+		d.DrawString(char)
+		d.Src = image.White
+		d.Dot = fixed.P(x, y)
+		d.DrawString(char)
+	}
+	return img
+}
+
+func compile(canvas xcf.Canvas, layerName, outputName string) {
+	layer := canvas.GetLayerByName(layerName)
+	savePng(
+		swapRedBlue(scaleImage(makeTransparentAreasBlack(layer), 0.25)),
+		outputName,
+	)
+}
+
+func loadXCF(name string) xcf.Canvas {
+	canvas, err := xcf.LoadFromFile(source(name + ".xcf"))
+	check(err)
+	return canvas
+}
+
+func savePng(img image.Image, name string) {
+	file, err := os.Create(generated(name + ".png"))
+	check(err)
+	defer file.Close()
+	check(png.Encode(file, img))
+}
+
+func loadPng(path string) image.Image {
+	file, err := os.Open(path)
+	check(err)
+	defer file.Close()
+	img, err := png.Decode(file)
+	check(err)
+	return img
+}
+
+func scaleImage(img image.Image, f float64) image.Image {
+	return resize.Resize(
+		uint(0.5+float64(img.Bounds().Dx())*f),
+		uint(0.5+float64(img.Bounds().Dy())*f),
+		img,
+		resize.Bicubic,
+	)
+}
+
+func swapRedBlue(img image.Image) image.Image {
+	b := img.Bounds()
+	swapped := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			swapped.Set(x, y, flipRB{img.At(x, y)})
+		}
+	}
+	return swapped
+}
+
+type flipRB struct {
+	color.Color
+}
+
+func (c flipRB) RGBA() (r, g, b, a uint32) {
+	b, g, r, a = c.Color.RGBA()
+	return
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func makeTransparentAreasBlack(original image.Image) image.Image {
+	b := original.Bounds()
+	img := image.NewRGBA(b)
+	draw.Draw(img, b, original, image.ZP, draw.Src)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a == 0 {
+				img.Set(x, y, color.RGBA{})
+			}
+		}
+	}
+	return img
+}
+
+func extractCollisionRect(img image.Image) game.Rectangle {
+	b := img.Bounds()
+
+	var right, bottom int
+	top, left := func() (int, int) {
+		for top := b.Min.Y; top < b.Max.Y; top++ {
+			for left := b.Min.X; left < b.Max.X; left++ {
+				if _, _, _, a := img.At(left, top).RGBA(); a > 0 {
+					return top, left
+				}
+			}
+		}
+		return 0, 0
+	}()
+
+	for right = left; right < b.Max.X; right++ {
+		if _, _, _, a := img.At(right, top).RGBA(); a == 0 {
+			break
+		}
+	}
+	for bottom = top; bottom < b.Max.Y; bottom++ {
+		if _, _, _, a := img.At(left, bottom).RGBA(); a == 0 {
+			break
+		}
+	}
+
+	return game.Rectangle{
+		X: left,
+		Y: top,
+		W: right - left,
+		H: bottom - top,
+	}
+}
+
+func scaleRect(r game.Rectangle, f float32) game.Rectangle {
+	r.X = int(float32(r.X)*f + 0.5)
+	r.Y = int(float32(r.Y)*f + 0.5)
+	r.W = int(float32(r.W)*f + 0.5)
+	r.H = int(float32(r.H)*f + 0.5)
+	return r
+}
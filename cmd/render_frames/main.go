@@ -0,0 +1,130 @@
+// render_frames runs the game without a window and writes the first frames of
+// a level as PNG files. The resources are read from the rsc folder so make sure
+// to run make_assets first.
+//
+//	go run ./cmd/render_frames -level 2 -frames 120 -out frames
+//
+// Given a replay file, the recorded session is rendered instead and -level and
+// -frames are ignored.
+//
+//	go run ./cmd/render_frames -replay session.rpl
+//
+// With -wav, the sounds that the game plays during the rendered frames are
+// mixed into a WAV file, one update's worth of samples per frame.
+//
+//	go run ./cmd/render_frames -level 0 -frames 600 -wav level_0.wav
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/ld36/audio"
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/headless"
+	"github.com/gonutz/ld36/replay"
+)
+
+var (
+	rscDir     = flag.String("rsc", "rsc", "directory containing the game resources")
+	outDir     = flag.String("out", "frames", "directory to write the frame PNGs to")
+	levelIndex = flag.Int("level", 0, "index of the level to render")
+	frameCount = flag.Int("frames", 60, "number of frames to render")
+	width      = flag.Int("width", 960, "screen width in pixels")
+	height     = flag.Int("height", 540, "screen height in pixels")
+	replayPath = flag.String("replay", "", "replay file to play back")
+	wavPath    = flag.String("wav", "", "WAV file to write the game's sound to")
+)
+
+// background is the clear color that the Windows frontend uses.
+var background = color.RGBA{0, 95, 83, 255}
+
+func main() {
+	flag.Parse()
+
+	check(os.MkdirAll(*outDir, 0777))
+
+	var rp *replay.Replay
+	if *replayPath != "" {
+		var err error
+		rp, err = readReplay(*replayPath)
+		check(err)
+		*levelIndex = rp.LevelIndex
+		*frameCount = len(rp.Frames)
+	}
+
+	res := headless.NewResources(headless.ReadFileFrom(*rscDir), *width, *height)
+	g := game.NewAtLevel(res, *levelIndex)
+	// the screen size is fixed by the flags, recorded size changes are not
+	// forwarded to the game
+	g.SetScreenSize(*width, *height)
+	frame := func() { g.Frame(nil) }
+	if rp != nil {
+		player := replay.NewPlayer(rp, fixedScreen{g})
+		frame = func() {
+			player.Step()
+			g.Draw()
+		}
+	}
+
+	samplesPerFrame := 2 * res.Mixer().SampleRate() / game.UpdatesPerSecond
+	var samples []int16
+	for i := 0; i < *frameCount; i++ {
+		res.Clear(background)
+		frame()
+		path := filepath.Join(*outDir, fmt.Sprintf("frame_%04d.png", i))
+		check(savePng(res, path))
+		if *wavPath != "" {
+			samples = append(samples, make([]int16, samplesPerFrame)...)
+			res.Mixer().Mix(samples[len(samples)-samplesPerFrame:])
+		}
+	}
+
+	if *wavPath != "" {
+		check(saveWav(res, *wavPath, samples))
+	}
+}
+
+type fixedScreen struct {
+	game.Game
+}
+
+func (fixedScreen) SetScreenSize(width, height int) {}
+
+func readReplay(path string) (*replay.Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return replay.Read(file)
+}
+
+func savePng(res *headless.Resources, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, res.Screen())
+}
+
+func saveWav(res *headless.Resources, path string, samples []int16) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return audio.WriteWav(file, res.Mixer().SampleRate(), samples)
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+// solve_levels searches for the key presses that take the caveman through each
+// level and writes them as replay files that render_frames can play back. The
+// resources are read from the rsc folder so make sure to run make_assets first.
+//
+//	go run ./cmd/solve_levels -out solutions
+//
+// It prints the number of frames until the caveman enters the gate for every
+// solved level and exits with status 1 if any level was not solved. By default
+// similar states are merged to make the search fast enough for levels with
+// rocks. To prove that a level cannot be solved, which is only feasible for
+// small levels, use
+//
+//	go run ./cmd/solve_levels -level 2 -exact -step 1
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/headless"
+	"github.com/gonutz/ld36/replay"
+)
+
+var (
+	rscDir     = flag.String("rsc", "rsc", "directory containing the game resources")
+	outDir     = flag.String("out", "solutions", "directory to write the replay files to")
+	levelIndex = flag.Int("level", -1, "index of the level to solve, -1 solves all levels")
+	step       = flag.Int("step", 8, "number of frames that the keys are held before changing them")
+	maxFrames  = flag.Int("max-frames", game.DefaultSolveMaxFrames, "maximum length of a solution in frames")
+	maxStates  = flag.Int("max-states", game.DefaultSolveMaxStates, "maximum number of states to search per level")
+	exact      = flag.Bool("exact", false, "tell apart all states instead of merging similar ones")
+)
+
+func main() {
+	flag.Parse()
+
+	data, err := ioutil.ReadFile(filepath.Join(*rscDir, "info.json"))
+	check(err)
+	var info game.Info
+	check(json.Unmarshal(data, &info))
+
+	levels := []int{*levelIndex}
+	if *levelIndex < 0 {
+		levels = nil
+		for i := 0; i < info.LevelCount; i++ {
+			levels = append(levels, i)
+		}
+	}
+
+	check(os.MkdirAll(*outDir, 0777))
+	options := game.SolveOptions{
+		MaxFrames: *maxFrames,
+		MaxStates: *maxStates,
+		Step:      *step,
+		Exact:     *exact,
+	}
+	unsolved := 0
+	for _, level := range levels {
+		name := fmt.Sprintf("level_%d", level)
+		res := headless.NewResources(headless.ReadFileFrom(*rscDir), 960, 540)
+		start := time.Now()
+		s, err := game.Solve(res, level, options)
+		took := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			fmt.Printf("%v.tmx: %v (%v)\n", name, err, took)
+			unsolved++
+			continue
+		}
+		path := filepath.Join(*outDir, name+".rpl")
+		check(writeReplay(path, replay.FromSolution(level, s)))
+		fmt.Printf("%v.tmx: gate after %v frames, %v with the gate animation (%v), wrote %v\n",
+			name, s.GateFrame, len(s.Frames), took, path)
+	}
+
+	if unsolved > 0 {
+		os.Exit(1)
+	}
+}
+
+func writeReplay(path string, rp *replay.Replay) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return rp.Write(file)
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,80 @@
+// validate_levels checks all .tmx files in a directory the way the game loads
+// them and prints every problem it finds. It exits with status 1 if there are
+// any problems, so it can be used to check level submissions.
+//
+//	go run ./cmd/validate_levels rsc
+//
+// Entities are checked against the hit boxes and gate width in info.json if that
+// exists in the directory, which make_assets generates.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gonutz/ld36/game"
+)
+
+func main() {
+	dir := "rsc"
+	if len(os.Args) == 2 {
+		dir = os.Args[1]
+	} else if len(os.Args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: validate_levels [directory]")
+		os.Exit(2)
+	}
+
+	var info game.Info
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "info.json")); err == nil {
+		if err := json.Unmarshal(data, &info); err != nil {
+			fail("unable to decode info.json:", err)
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmx"))
+	if err != nil {
+		fail(err)
+	}
+	if len(paths) == 0 {
+		fail("no .tmx files found in", dir)
+	}
+	sort.Strings(paths)
+
+	loadFile := func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, name))
+	}
+
+	problemCount := 0
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fail(err)
+		}
+		for _, p := range game.ValidateLevel(data, loadFile, info) {
+			fmt.Printf("%v:%v\n", filepath.Base(path), formatProblem(p))
+			problemCount++
+		}
+	}
+
+	if problemCount > 0 {
+		fmt.Printf("%v problem(s) in %v level(s)\n", problemCount, len(paths))
+		os.Exit(1)
+	}
+	fmt.Printf("all %v level(s) are fine\n", len(paths))
+}
+
+func formatProblem(p game.LevelProblem) string {
+	if p.X < 0 || p.Y < 0 {
+		return " " + p.Message
+	}
+	return p.Error()
+}
+
+func fail(a ...interface{}) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(2)
+}
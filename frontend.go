@@ -0,0 +1,156 @@
+//go:build linux || windows
+
+package main
+
+import (
+	"flag"
+	"math"
+	"os"
+	"time"
+
+	"github.com/gonutz/ld36/batch"
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/log"
+	"github.com/gonutz/ld36/replay"
+)
+
+var recordPath = flag.String("record", "", "record the session's input to this replay file")
+
+// startGame creates the game, or a recording of it if the record flag is set.
+// Call the returned function when the game is over.
+func startGame(res game.Resources) (game.Game, func()) {
+	if *recordPath == "" {
+		return game.New(res, progressStore()), func() {}
+	}
+
+	// replays start right in the level, the title screen is skipped
+	g := game.NewAtLevel(res, 0)
+	replayFile, err := os.Create(*recordPath)
+	if err != nil {
+		log.Println("unable to create replay file: ", err)
+		return g, func() {}
+	}
+	recorder := replay.NewRecorder(g, replayFile, 0)
+	return recorder, func() {
+		if err := recorder.Close(); err != nil {
+			log.Println("unable to write replay: ", err)
+		}
+		replayFile.Close()
+	}
+}
+
+// the simulation runs at a fixed rate, independent of the monitor's refresh
+// rate; every frame we run as many updates as are due and draw once
+const (
+	updateInterval = time.Second / game.UpdatesPerSecond
+	// if we fall behind more than this, e.g. while the window is dragged,
+	// the game slows down instead of catching up in one big burst
+	maxFrameTime = 250 * time.Millisecond
+)
+
+type simulation struct {
+	game        game.Game
+	unsimulated time.Duration
+	lastTime    time.Time
+	// interpolate draws between updates, otherwise only whole updates are
+	// drawn
+	interpolate bool
+}
+
+func newSimulation(g game.Game) *simulation {
+	return &simulation{game: g, lastTime: time.Now(), interpolate: true}
+}
+
+// advance runs the updates that are due since the last call. The events go to
+// the first update, they are cleared once an update used them. The returned t
+// is the time between the last update and the next one, for
+// Game.DrawInterpolated.
+func (s *simulation) advance(events *[]game.InputEvent) float32 {
+	now := time.Now()
+	frameTime := now.Sub(s.lastTime)
+	s.lastTime = now
+	if frameTime > maxFrameTime {
+		frameTime = maxFrameTime
+	}
+	s.unsimulated += frameTime
+
+	for s.unsimulated >= updateInterval {
+		s.game.Update(*events)
+		*events = (*events)[0:0]
+		s.unsimulated -= updateInterval
+	}
+
+	if !s.interpolate {
+		return 1
+	}
+	return float32(s.unsimulated) / float32(updateInterval)
+}
+
+// spriteQuad places the source part of a w by h texture on a screen with y
+// going down, drawn at x,y in game coordinates. pixelOffset moves it by that
+// many pixels up and left, for APIs that sample texels at their corners.
+func spriteQuad(
+	x, y, screenH, w, h int,
+	source game.Rectangle,
+	options game.DrawOptions,
+	pixelOffset float32,
+) batch.Quad {
+	scaleX, scaleY := options.Scale()
+	fw, fh := float32(source.W)*scaleX, float32(source.H)*scaleY
+	// the coordinate system for drawing goes from bottom to top
+	fx, fy := float32(x), float32(screenH-1-y)-fh
+
+	x1, y1 := -fw/2, -fh/2
+	x2, y2 := fw/2, -fh/2
+	x3, y3 := -fw/2, fh/2
+	x4, y4 := fw/2, fh/2
+
+	if options.FlipX {
+		x1, x2, x3, x4 = x2, x1, x4, x3
+	}
+	if options.FlipY {
+		y1, y2, y3, y4 = y3, y4, y1, y2
+	}
+
+	if degrees := options.CenterRotationDeg; degrees != 0 {
+		s, c := math.Sincos(float64(degrees) / 180 * math.Pi)
+		sin, cos := float32(s), float32(c)
+		// rotate around the pivot, y goes down on the screen
+		px, py := options.PivotX*scaleX, -options.PivotY*scaleY
+		rotate := func(x, y float32) (float32, float32) {
+			x, y = x-px, y-py
+			return cos*x - sin*y + px, sin*x + cos*y + py
+		}
+		x1, y1 = rotate(x1, y1)
+		x2, y2 = rotate(x2, y2)
+		x3, y3 = rotate(x3, y3)
+		x4, y4 = rotate(x4, y4)
+	}
+
+	dx := fx + fw/2 - pixelOffset
+	dy := fy + fh/2 - pixelOffset
+	color := toARGB(options.Color())
+	du, dv := 1/float32(w), 1/float32(h)
+	u0, u1 := float32(source.X)*du, float32(source.X+source.W)*du
+	v0, v1 := float32(source.Y)*dv, float32(source.Y+source.H)*dv
+	return batch.Quad{
+		{X: x1 + dx, Y: y1 + dy, U: u0, V: v0, Color: color},
+		{X: x2 + dx, Y: y2 + dy, U: u1, V: v0, Color: color},
+		{X: x3 + dx, Y: y3 + dy, U: u0, V: v1, Color: color},
+		{X: x4 + dx, Y: y4 + dy, U: u1, V: v1, Color: color},
+	}
+}
+
+// toARGB converts the color to ARGB with 8 bits per component.
+func toARGB(c game.Color) uint32 {
+	component := func(f float32) uint32 {
+		if f <= 0 {
+			return 0
+		}
+		if f >= 1 {
+			return 255
+		}
+		return uint32(f*255 + 0.5)
+	}
+	return component(c.A)<<24 | component(c.R)<<16 | component(c.G)<<8 | component(c.B)
+}
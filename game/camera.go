@@ -0,0 +1,309 @@
+package game
+
+import "math"
+
+// cameraSettings control how the camera follows the caveman. Levels can change
+// them with the map properties camera_dead_zone_width, camera_dead_zone_height,
+// camera_smoothing and camera_look_ahead.
+type cameraSettings struct {
+	// deadZoneW and deadZoneH are the size of the area around the screen's
+	// center in which the caveman moves without moving the camera.
+	deadZoneW, deadZoneH float64
+	// smoothing is the part of the distance to its target that the camera
+	// moves in one update, 1 keeps the target exactly in place.
+	smoothing float64
+	// lookAhead is how far the camera looks ahead of the caveman in the
+	// direction that it faces, in pixels.
+	lookAhead float64
+}
+
+var defaultCameraSettings = cameraSettings{
+	deadZoneW: 80,
+	deadZoneH: 160,
+	smoothing: 0.1,
+	lookAhead: 160,
+}
+
+func readCameraSettings(p properties) cameraSettings {
+	d := defaultCameraSettings
+	return cameraSettings{
+		deadZoneW: p.float("camera_dead_zone_width", d.deadZoneW),
+		deadZoneH: p.float("camera_dead_zone_height", d.deadZoneH),
+		smoothing: p.float("camera_smoothing", d.smoothing),
+		lookAhead: p.float("camera_look_ahead", d.lookAhead),
+	}
+}
+
+const (
+	// shakeDecay is multiplied with the shake's strength after each update.
+	shakeDecay = 0.85
+	// maxShake is the strongest shake in pixels.
+	maxShake = 20
+)
+
+// camera moves the view over the world. It only changes what is drawn, never
+// the simulation. The offsets are added to world coordinates to get screen
+// coordinates.
+type camera struct {
+	offsetX, offsetY int
+	screenW, screenH int
+	worldW, worldH   int
+
+	settings cameraSettings
+	// focusX, focusY is the world position in the screen's center without
+	// shaking
+	focusX, focusY float64
+	// lookAhead moves smoothly between -settings.lookAhead and
+	// settings.lookAhead when the caveman turns around
+	lookAhead float64
+	following bool
+	// shake is the current strength of the shaking in pixels, shakeTime
+	// counts the updates while shaking
+	shake     float64
+	shakeTime int
+}
+
+func (c *camera) setWorldSize(w, h int) {
+	c.worldW, c.worldH = w, h
+}
+
+func (c *camera) setScreenSize(w, h int) {
+	c.screenW, c.screenH = w, h
+}
+
+// follow moves the camera towards x,y, looking ahead in the direction that the
+// target faces. The first call puts the target right in the center.
+func (c *camera) follow(x, y int, facesRight bool) {
+	s := c.settings
+	lookAhead := -s.lookAhead
+	if facesRight {
+		lookAhead = s.lookAhead
+	}
+	targetX, targetY := float64(x), float64(y)
+	if !c.following {
+		c.following = true
+		c.lookAhead = lookAhead
+		c.focusX, c.focusY = targetX+lookAhead, targetY
+	} else {
+		c.lookAhead += (lookAhead - c.lookAhead) * s.smoothing
+		goalX := outsideDeadZone(c.focusX, targetX+c.lookAhead, s.deadZoneW/2)
+		goalY := outsideDeadZone(c.focusY, targetY, s.deadZoneH/2)
+		c.focusX += (goalX - c.focusX) * s.smoothing
+		c.focusY += (goalY - c.focusY) * s.smoothing
+	}
+	c.focusX = clampFocus(c.focusX, c.screenW, c.worldW)
+	c.focusY = clampFocus(c.focusY, c.screenH, c.worldH)
+
+	c.offsetX = round(float64(c.screenW)/2 - c.focusX)
+	c.offsetY = round(float64(c.screenH)/2 - c.focusY)
+	if c.shake > 0 {
+		// two sine waves with different frequencies make it look random
+		// while staying the same in replays
+		t := float64(c.shakeTime)
+		c.offsetX += round(c.shake * math.Sin(t*2.1))
+		c.offsetY += round(c.shake * math.Sin(t*2.9+1))
+		c.shakeTime++
+		c.shake *= shakeDecay
+		if c.shake < 0.5 {
+			c.stopShaking()
+		}
+	}
+}
+
+// outsideDeadZone returns the position closest to focus that has the target
+// within the given distance.
+func outsideDeadZone(focus, target, halfZone float64) float64 {
+	if target > focus+halfZone {
+		return target - halfZone
+	}
+	if target < focus-halfZone {
+		return target + halfZone
+	}
+	return focus
+}
+
+// clampFocus keeps the screen inside the world. If the world is smaller than
+// the screen, it is centered.
+func clampFocus(focus float64, screenSize, worldSize int) float64 {
+	half := float64(screenSize) / 2
+	if worldSize < screenSize {
+		return float64(worldSize) / 2
+	}
+	if focus < half {
+		return half
+	}
+	if focus > float64(worldSize)-half {
+		return float64(worldSize) - half
+	}
+	return focus
+}
+
+// startShaking shakes the view with the given strength in pixels. A stronger
+// shake replaces a weaker one that is still going on.
+func (c *camera) startShaking(strength float64) {
+	if strength > maxShake {
+		strength = maxShake
+	}
+	if strength > c.shake {
+		c.shake = strength
+	}
+}
+
+func (c *camera) stopShaking() {
+	c.shake, c.shakeTime = 0, 0
+}
+
+func (c *camera) transformXY(x, y int) (int, int) {
+	return x + c.offsetX, y + c.offsetY
+}
+
+// visibleRect is the part of the world that is on the screen.
+func (c *camera) visibleRect() Rectangle {
+	return Rectangle{X: -c.offsetX, Y: -c.offsetY, W: c.screenW, H: c.screenH}
+}
+
+// sees reports whether any part of a w by h image, drawn in world coordinates
+// at x,y with the given options, is on the screen. Rotated images are tested
+// with a square that contains them in any rotation.
+func (c *camera) sees(x, y, w, h int, options DrawOptions) bool {
+	scaleX, scaleY := options.Scale()
+	fw, fh := float64(w)*float64(scaleX), float64(h)*float64(scaleY)
+	left, bottom := float64(x+c.offsetX), float64(y+c.offsetY)
+	right, top := left+fw, bottom+fh
+	if options.CenterRotationDeg != 0 {
+		pivot := math.Hypot(float64(options.PivotX*scaleX), float64(options.PivotY*scaleY))
+		radius := math.Hypot(fw, fh)/2 + 2*pivot
+		centerX, centerY := left+fw/2, bottom+fh/2
+		left, right = centerX-radius, centerX+radius
+		bottom, top = centerY-radius, centerY+radius
+	}
+	return right > 0 && left < float64(c.screenW) && top > 0 && bottom < float64(c.screenH)
+}
+
+// cameraImage draws in world coordinates. Images that are not on the screen
+// are not drawn at all.
+type cameraImage struct {
+	Image
+	camera *camera
+}
+
+func (img cameraImage) DrawAt(x, y int) {
+	w, h := img.Size()
+	if img.camera.sees(x, y, w, h, DrawOptions{}) {
+		img.Image.DrawAt(img.camera.transformXY(x, y))
+	}
+}
+
+func (img cameraImage) DrawAtEx(x, y int, options DrawOptions) {
+	w, h := img.Size()
+	if img.camera.sees(x, y, w, h, options) {
+		x, y = img.camera.transformXY(x, y)
+		img.Image.DrawAtEx(x, y, options)
+	}
+}
+
+func (img cameraImage) DrawRectAt(x, y int, source Rectangle) {
+	if img.camera.sees(x, y, source.W, source.H, DrawOptions{}) {
+		x, y = img.camera.transformXY(x, y)
+		img.Image.DrawRectAt(x, y, source)
+	}
+}
+
+func (img cameraImage) DrawRectAtEx(x, y int, source Rectangle, options DrawOptions) {
+	if img.camera.sees(x, y, source.W, source.H, options) {
+		x, y = img.camera.transformXY(x, y)
+		img.Image.DrawRectAtEx(x, y, source, options)
+	}
+}
+
+const (
+	// VirtualWidth and VirtualHeight are the screen size that the game is
+	// made for. Everything is drawn at this size and then scaled to fit the
+	// window, so players see the same part of the world in any window.
+	VirtualWidth  = 1920
+	VirtualHeight = 1080
+)
+
+// viewport scales the virtual screen into the window. If their aspect ratios
+// differ, the virtual screen is centered and the rest of the window is covered
+// with black bars.
+type viewport struct {
+	// zoom is the size of a virtual pixel in window pixels
+	zoom float64
+	// x, y is the bottom-left corner and w, h the size of the virtual screen
+	// in the window
+	x, y, w, h       int
+	windowW, windowH int
+}
+
+func fitViewport(windowW, windowH int) viewport {
+	zoom := math.Min(
+		float64(windowW)/VirtualWidth,
+		float64(windowH)/VirtualHeight,
+	)
+	if zoom < 0 {
+		zoom = 0
+	}
+	w, h := round(VirtualWidth*zoom), round(VirtualHeight*zoom)
+	return viewport{
+		zoom:    zoom,
+		x:       (windowW - w) / 2,
+		y:       (windowH - h) / 2,
+		w:       w,
+		h:       h,
+		windowW: windowW,
+		windowH: windowH,
+	}
+}
+
+// apply moves all commands from virtual to window coordinates and adds the
+// black bars around the virtual screen. Both edges of every tile are rounded
+// to window pixels and the tile is stretched between them, so that neighboring
+// tiles still touch after zooming by any factor. Other images keep their size
+// while they move.
+func (v viewport) apply(list *DrawList, bar Image) {
+	if v.zoom == 0 {
+		// the window is minimized
+		list.Reset()
+		return
+	}
+	if v.zoom != 1 || v.x != 0 || v.y != 0 {
+		zoom := float32(v.zoom)
+		for i := range list.Commands {
+			c := &list.Commands[i]
+			scaleX, scaleY := c.Options.Scale()
+			x, w := v.edges(c.X, c.Source.W, scaleX)
+			y, h := v.edges(c.Y, c.Source.H, scaleY)
+			c.X, c.Y = v.x+x, v.y+y
+			if c.Layer == LayerTiles && c.Source.W != 0 && c.Source.H != 0 {
+				c.Options.ScaleX = float32(w) / float32(c.Source.W)
+				c.Options.ScaleY = float32(h) / float32(c.Source.H)
+			} else {
+				c.Options.ScaleX, c.Options.ScaleY = scaleX*zoom, scaleY*zoom
+			}
+		}
+	}
+	barW, barH := bar.Size()
+	drawBar := func(x, y, w, h int) {
+		if w > 0 && h > 0 {
+			bar.DrawAtEx(x, y, DrawOptions{
+				ScaleX: float32(w) / float32(barW),
+				ScaleY: float32(h) / float32(barH),
+				Tint:   Color{A: 1},
+			})
+		}
+	}
+	drawBar(0, 0, v.x, v.windowH)
+	drawBar(v.x+v.w, 0, v.windowW-v.x-v.w, v.windowH)
+	drawBar(v.x, 0, v.w, v.y)
+	drawBar(v.x, v.y+v.h, v.w, v.windowH-v.y-v.h)
+}
+
+// edges zooms the start and end of an image that starts at pos and has size
+// pixels scaled by scale. It returns the zoomed start and size, both in whole
+// window pixels.
+func (v viewport) edges(pos, size int, scale float32) (int, int) {
+	start := round(float64(pos) * v.zoom)
+	end := round((float64(pos) + float64(size)*float64(scale)) * v.zoom)
+	return start, end - start
+}
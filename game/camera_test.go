@@ -0,0 +1,180 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+func newTestCamera(settings cameraSettings) *camera {
+	c := &camera{settings: settings}
+	c.setScreenSize(200, 100)
+	c.setWorldSize(2000, 1000)
+	return c
+}
+
+func TestCameraStartsCenteredOnTarget(t *testing.T) {
+	c := newTestCamera(defaultCameraSettings)
+	c.follow(500, 300, false)
+	// looking ahead to the left
+	if c.focusX != 500-defaultCameraSettings.lookAhead || c.focusY != 300 {
+		t.Errorf("focus is %v,%v", c.focusX, c.focusY)
+	}
+	if x, y := c.transformXY(500, 300); x != 100+int(defaultCameraSettings.lookAhead) || y != 50 {
+		t.Errorf("target is at %v,%v on the screen", x, y)
+	}
+}
+
+func TestCameraDoesNotMoveInsideTheDeadZone(t *testing.T) {
+	c := newTestCamera(cameraSettings{deadZoneW: 40, deadZoneH: 20, smoothing: 1})
+	c.follow(500, 300, true)
+	c.follow(519, 309, true)
+	if c.focusX != 500 || c.focusY != 300 {
+		t.Errorf("camera moved to %v,%v inside the dead zone", c.focusX, c.focusY)
+	}
+	c.follow(530, 290, true)
+	if c.focusX != 510 || c.focusY != 300 {
+		t.Errorf("want the target at the dead zone's edge but focus is %v,%v", c.focusX, c.focusY)
+	}
+}
+
+func TestCameraMovesSmoothlyTowardsTarget(t *testing.T) {
+	c := newTestCamera(cameraSettings{smoothing: 0.5, lookAhead: 100})
+	c.follow(500, 300, true)
+	c.follow(500, 400, true)
+	if c.focusY != 350 {
+		t.Errorf("want half the way to the target but focus is at y %v", c.focusY)
+	}
+	// turning around moves the look-ahead smoothly as well
+	c.follow(500, 400, false)
+	if c.lookAhead != 0 || c.focusX != 550 {
+		t.Errorf("look-ahead is %v and focus is at x %v", c.lookAhead, c.focusX)
+	}
+}
+
+func TestCameraStaysInTheWorld(t *testing.T) {
+	c := newTestCamera(cameraSettings{smoothing: 1})
+	c.follow(10, 990, false)
+	if c.offsetX != 0 || c.offsetY != -900 {
+		t.Errorf("want offsets 0,-900 but have %v,%v", c.offsetX, c.offsetY)
+	}
+	c.setWorldSize(100, 50)
+	c.follow(0, 0, false)
+	if c.offsetX != 50 || c.offsetY != 25 {
+		t.Errorf("small world is not centered, offsets are %v,%v", c.offsetX, c.offsetY)
+	}
+}
+
+func TestShakingDiesDown(t *testing.T) {
+	c := newTestCamera(cameraSettings{smoothing: 1})
+	c.follow(500, 300, false)
+	c.startShaking(100)
+	if c.shake != maxShake {
+		t.Errorf("want the shake limited to %v but have %v", maxShake, c.shake)
+	}
+	moved := false
+	for i := 0; i < 100; i++ {
+		c.follow(500, 300, false)
+		if c.offsetX != -400 || c.offsetY != -250 {
+			moved = true
+		}
+	}
+	if !moved {
+		t.Error("the camera did not shake")
+	}
+	if c.shake != 0 || c.offsetX != -400 || c.offsetY != -250 {
+		t.Errorf("still shaking with %v, offsets %v,%v", c.shake, c.offsetX, c.offsetY)
+	}
+}
+
+func TestCameraSeesImagesThatTouchTheScreen(t *testing.T) {
+	c := camera{offsetX: -100, offsetY: -50, screenW: 200, screenH: 100}
+	for _, test := range []struct {
+		x, y, w, h int
+		options    DrawOptions
+		want       bool
+	}{
+		{100, 50, 10, 10, DrawOptions{}, true},
+		{90, 50, 10, 10, DrawOptions{}, false},
+		{91, 50, 10, 10, DrawOptions{}, true},
+		{300, 50, 10, 10, DrawOptions{}, false},
+		{299, 149, 10, 10, DrawOptions{}, true},
+		{100, 150, 10, 10, DrawOptions{}, false},
+		{80, 50, 10, 10, DrawOptions{ScaleX: 2}, false},
+		{80, 50, 10, 10, DrawOptions{ScaleX: 2.1}, true},
+		// the corners of a rotated image reach out further
+		{88, 50, 10, 10, DrawOptions{CenterRotationDeg: 45}, true},
+		{80, 50, 10, 10, DrawOptions{CenterRotationDeg: 45, PivotX: 5}, true},
+	} {
+		if have := c.sees(test.x, test.y, test.w, test.h, test.options); have != test.want {
+			t.Errorf("image at %v,%v size %vx%v %+v: want %v but have %v",
+				test.x, test.y, test.w, test.h, test.options, test.want, have)
+		}
+	}
+}
+
+func TestFallingRockLandsHard(t *testing.T) {
+	var m tileMap
+	m.setSize(1, 20)
+	m.tileW, m.tileH = 160, 160
+	m.tileAt(0, 0).isSolid = true
+	r := rock{Rectangle: Rectangle{Y: 2000, W: 160, H: 160}, mass: 1}
+
+	landed := 0
+	for i := 0; i < 100 && landed == 0; i++ {
+		landed = r.update(&m, Rectangle{X: -1000}, []rock{r}, 0)
+	}
+	if landed <= hardLandingSpeed {
+		t.Errorf("want a hard landing but the rock landed with speed %v", landed)
+	}
+}
+
+func TestViewportScalesAndLetterboxes(t *testing.T) {
+	v := fitViewport(960, 720)
+	if v.zoom != 0.5 || v.x != 0 || v.y != 90 || v.w != 960 || v.h != 540 {
+		t.Fatalf("viewport is %+v", v)
+	}
+
+	var list DrawList
+	list.Add(DrawCommand{Image: "rock", X: 100, Y: 200, Source: Rectangle{W: 160, H: 160}})
+	bar := listImage{id: "pixel", layer: LayerLetterbox, list: &list, w: 1, h: 1}
+	v.apply(&list, bar)
+	want := "0 rock 50 190 rect 0,0,160,160 scale=0.500,0.500\n" +
+		"7 pixel 0 0 rect 0,0,1,1 scale=960.000,90.000 tint=0.000,0.000,0.000,1.000\n" +
+		"7 pixel 0 630 rect 0,0,1,1 scale=960.000,90.000 tint=0.000,0.000,0.000,1.000\n"
+	if have := list.String(); have != want {
+		t.Errorf("want\n%vbut have\n%v", want, have)
+	}
+}
+
+func TestViewportTilesTouchAtAnyZoom(t *testing.T) {
+	v := fitViewport(1280, 720)
+	var list DrawList
+	for i := 0; i < 4; i++ {
+		list.Add(DrawCommand{
+			Image:  "tiles",
+			X:      i*160 - 33,
+			Y:      i*160 + 7,
+			Source: Rectangle{W: 160, H: 160},
+			Layer:  LayerTiles,
+		})
+	}
+	v.apply(&list, listImage{list: &list, w: 1, h: 1})
+	for i := 1; i < 4; i++ {
+		prev, c := list.Commands[i-1], list.Commands[i]
+		right := float64(prev.X) + float64(prev.Options.ScaleX)*160
+		top := float64(prev.Y) + float64(prev.Options.ScaleY)*160
+		if math.Abs(right-float64(c.X)) > 0.001 || math.Abs(top-float64(c.Y)) > 0.001 {
+			t.Errorf("tile %v ends at %v,%v but the next one starts at %v,%v",
+				i-1, right, top, c.X, c.Y)
+		}
+	}
+}
+
+func TestViewportDrawsNothingInAMinimizedWindow(t *testing.T) {
+	var list DrawList
+	list.Add(DrawCommand{Image: "rock"})
+	fitViewport(0, 0).apply(&list, listImage{list: &list, w: 1, h: 1})
+	if len(list.Commands) != 0 {
+		t.Errorf("want no commands but have\n%v", list.String())
+	}
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gonutz/ld36/batch"
+)
+
+// BenchmarkDrawLargestLevel draws the level with the most tiles. It reports how
+// many tile images the level has, how many images are drawn after culling and
+// how many draw calls a batching backend makes for them.
+func BenchmarkDrawLargestLevel(b *testing.B) {
+	res := &batchingResources{recordingResources: newRecordingResources(b)}
+	res.batch = batch.New(func(interface{}, []batch.Quad) {})
+
+	var largest *gameFrame
+	for i := 0; i < levelCount; i++ {
+		f := NewAtLevel(res, i).(*gameFrame)
+		m := f.game.tileMap
+		if largest == nil || m.width*m.height > largest.game.tileMap.width*largest.game.tileMap.height {
+			largest = f
+		}
+	}
+	tileImages := 0
+	for _, t := range largest.game.tileMap.tiles {
+		tileImages += len(t.images)
+	}
+	f := largest
+	f.SetScreenSize(960, 540)
+	f.Update(nil)
+	res.draws = 0
+	res.batch.DrawCalls()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Draw()
+		res.batch.Flush()
+	}
+	b.ReportMetric(float64(tileImages), "tiles")
+	b.ReportMetric(float64(res.draws)/float64(b.N), "draws/op")
+	b.ReportMetric(float64(res.batch.DrawCalls())/float64(b.N), "batches/op")
+}
+
+// batchingResources counts the draws and adds them to a batch, using the
+// image ids as textures.
+type batchingResources struct {
+	*recordingResources
+	batch *batch.Batch
+	draws int
+}
+
+func (r *batchingResources) LoadImage(id string) Image {
+	img := r.recordingResources.LoadImage(id).(*testImage)
+	return &batchingImage{id: id, w: img.w, h: img.h, r: r}
+}
+
+type batchingImage struct {
+	id   string
+	w, h int
+	r    *batchingResources
+}
+
+func (img *batchingImage) add() {
+	img.r.draws++
+	img.r.batch.Add(img.id, batch.Quad{})
+}
+
+func (img *batchingImage) DrawAt(x, y int)                                              { img.add() }
+func (img *batchingImage) DrawAtEx(x, y int, options DrawOptions)                       { img.add() }
+func (img *batchingImage) DrawRectAt(x, y int, source Rectangle)                        { img.add() }
+func (img *batchingImage) DrawRectAtEx(x, y int, source Rectangle, options DrawOptions) { img.add() }
+func (img *batchingImage) Size() (int, int)                                             { return img.w, img.h }
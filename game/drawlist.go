@@ -0,0 +1,175 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Layer orders the draw commands of a frame. Lower layers are drawn first and
+// commands in the same layer are drawn in the order that they were made.
+type Layer int
+
+const (
+	LayerTiles Layer = iota
+	LayerRocks
+	LayerGate
+	LayerCaveman
+	// LayerEffects is for effects over the level, like the cloud that the
+	// caveman disappears in.
+	LayerEffects
+	// LayerHUD is in screen coordinates over the level.
+	LayerHUD
+	LayerMenu
+	// LayerLetterbox has the bars around the virtual screen, in window
+	// coordinates.
+	LayerLetterbox
+)
+
+// DrawCommand draws the source part of an image with its bottom-left corner at
+// X,Y in screen coordinates.
+type DrawCommand struct {
+	Image   string
+	Source  Rectangle
+	X, Y    int
+	Options DrawOptions
+	Layer   Layer
+}
+
+// DrawList is what the game draws in one frame. Backends draw it by calling
+// Replay, tests and tools can look at the commands directly.
+type DrawList struct {
+	Commands []DrawCommand
+}
+
+func (l *DrawList) Reset() {
+	l.Commands = l.Commands[:0]
+}
+
+func (l *DrawList) Add(c DrawCommand) {
+	l.Commands = append(l.Commands, c)
+}
+
+// Sort orders the commands by layer and keeps the order within each layer.
+func (l *DrawList) Sort() {
+	sort.SliceStable(l.Commands, func(i, j int) bool {
+		return l.Commands[i].Layer < l.Commands[j].Layer
+	})
+}
+
+// Replay draws all commands in order with the images from the resources. The
+// resources should not load an image again for every call to LoadImage.
+func (l *DrawList) Replay(resources Resources) {
+	for _, c := range l.Commands {
+		resources.LoadImage(c.Image).DrawRectAtEx(c.X, c.Y, c.Source, c.Options)
+	}
+}
+
+// WriteTo writes one line of text for every command, in the form
+//
+//	layer image x y rect x,y,w,h [options]
+//
+// where the options are only written if they are not the defaults, e.g.
+// "flipX" or "rotation=90.000".
+func (l *DrawList) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+	var n int64
+	for _, c := range l.Commands {
+		written, err := fmt.Fprintf(buf, "%d %s %d %d rect %d,%d,%d,%d%s\n",
+			c.Layer, c.Image, c.X, c.Y,
+			c.Source.X, c.Source.Y, c.Source.W, c.Source.H,
+			formatDrawOptions(c.Options))
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, buf.Flush()
+}
+
+func (l *DrawList) String() string {
+	var b strings.Builder
+	l.WriteTo(&b)
+	return b.String()
+}
+
+func formatDrawOptions(o DrawOptions) string {
+	var s string
+	if o.FlipX {
+		s += " flipX"
+	}
+	if o.FlipY {
+		s += " flipY"
+	}
+	if o.Transparency != 0 {
+		s += " transparency=" + formatFloat(o.Transparency)
+	}
+	if o.CenterRotationDeg != 0 {
+		s += " rotation=" + formatFloat(o.CenterRotationDeg)
+	}
+	if o.PivotX != 0 || o.PivotY != 0 {
+		s += " pivot=" + formatFloat(o.PivotX) + "," + formatFloat(o.PivotY)
+	}
+	if o.ScaleX != 0 || o.ScaleY != 0 {
+		x, y := o.Scale()
+		s += " scale=" + formatFloat(x) + "," + formatFloat(y)
+	}
+	if o.Tint != (Color{}) {
+		s += " tint=" + formatFloat(o.Tint.R) + "," + formatFloat(o.Tint.G) +
+			"," + formatFloat(o.Tint.B) + "," + formatFloat(o.Tint.A)
+	}
+	return s
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', 3, 32)
+}
+
+// listImage adds a command to the draw list for every draw call.
+type listImage struct {
+	id    string
+	layer Layer
+	list  *DrawList
+	// size is the size of the image in the backend
+	w, h int
+}
+
+// newListImage loads the image from the resources to know its size.
+func newListImage(resources Resources, list *DrawList, id string, layer Layer) listImage {
+	w, h := resources.LoadImage(id).Size()
+	return listImage{id: id, layer: layer, list: list, w: w, h: h}
+}
+
+func (img listImage) DrawAt(x, y int) {
+	img.DrawRectAtEx(x, y, img.bounds(), DrawOptions{})
+}
+
+func (img listImage) DrawAtEx(x, y int, options DrawOptions) {
+	img.DrawRectAtEx(x, y, img.bounds(), options)
+}
+
+func (img listImage) DrawRectAt(x, y int, source Rectangle) {
+	img.DrawRectAtEx(x, y, source, DrawOptions{})
+}
+
+func (img listImage) DrawRectAtEx(x, y int, source Rectangle, options DrawOptions) {
+	img.list.Add(DrawCommand{
+		Image:   img.id,
+		Source:  source,
+		X:       x,
+		Y:       y,
+		Options: options,
+		Layer:   img.layer,
+	})
+}
+
+func (img listImage) Size() (int, int) {
+	return img.w, img.h
+}
+
+func (img listImage) bounds() Rectangle {
+	return Rectangle{W: img.w, H: img.h}
+}
@@ -0,0 +1,47 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDrawListIsSortedByLayer(t *testing.T) {
+	var l DrawList
+	l.Add(DrawCommand{Image: "controls", Layer: LayerHUD})
+	l.Add(DrawCommand{Image: "caveman", Layer: LayerCaveman, Options: DrawOptions{FlipX: true}})
+	l.Add(DrawCommand{Image: "tiles", X: 160, Source: Rectangle{W: 160, H: 160}})
+	l.Add(DrawCommand{Image: "rock", Layer: LayerRocks, Options: DrawOptions{CenterRotationDeg: 90}})
+	l.Add(DrawCommand{Image: "tiles", X: 320, Source: Rectangle{X: 160, W: 160, H: 160}})
+	l.Sort()
+
+	want := strings.Join([]string{
+		"0 tiles 160 0 rect 0,0,160,160",
+		"0 tiles 320 0 rect 160,0,160,160",
+		"1 rock 0 0 rect 0,0,0,0 rotation=90.000",
+		"3 caveman 0 0 rect 0,0,0,0 flipX",
+		"5 controls 0 0 rect 0,0,0,0",
+	}, "\n") + "\n"
+	if have := l.String(); have != want {
+		t.Errorf("want\n%vbut have\n%v", want, have)
+	}
+}
+
+func TestMenusAreDrawnOverTheLevel(t *testing.T) {
+	f := NewAtLevel(newRecordingResources(t), 0)
+	f.SetScreenSize(960, 540)
+	f.Update(press(KeyPause))
+
+	l := f.DrawList(1)
+	if len(l.Commands) == 0 {
+		t.Fatal("nothing is drawn")
+	}
+	last := l.Commands[len(l.Commands)-1]
+	if last.Image != "font" || last.Layer != LayerMenu {
+		t.Errorf("the menu text is not drawn last, the last command is %+v", last)
+	}
+	for _, c := range l.Commands {
+		if c.Image == "tiles" && c.Layer != LayerTiles {
+			t.Errorf("tile in layer %v", c.Layer)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package game
+
+import "fmt"
+
+// entity is something that is placed into a level by its type name, either as
+// a tile from a tileset with the "entities" property or as an object in an
+// object layer. x,y is the world position of its bottom-left corner.
+type entity struct {
+	typeName   string
+	x, y       int
+	properties properties
+}
+
+// entityTypes creates the entities in a level by their type name. To add a new
+// kind of entity, give it a type in Tiled and add a function for it here.
+var entityTypes = map[string]func(g *game, e entity){
+	"player": (*game).placePlayer,
+	"gate":   (*game).placeGate,
+	"rock":   (*game).placeRock,
+}
+
+func (g *game) placeEntity(e entity) error {
+	place, ok := entityTypes[e.typeName]
+	if !ok {
+		return fmt.Errorf("unknown entity type %q", e.typeName)
+	}
+	place(g, e)
+	return nil
+}
+
+func (e entity) facesRight() bool {
+	return e.properties["facing"] == "right"
+}
+
+func (g *game) placePlayer(e entity) {
+	g.cavemanFacesRight = e.facesRight()
+	g.cavemanX, g.cavemanY = e.x, e.y
+}
+
+func (g *game) placeGate(e entity) {
+	g.exitFacesRight = e.facesRight()
+	g.exitX, g.exitY = e.x, e.y
+}
+
+func (g *game) placeRock(e entity) {
+	r := rock{
+		Rectangle: Rectangle{
+			X: e.x + g.rockHitBox.X,
+			Y: e.y + g.rockHitBox.Y,
+			W: g.rockHitBox.W,
+			H: g.rockHitBox.H,
+		},
+		mass: float32(e.properties.float("mass", 1)),
+	}
+	if r.mass <= 0 {
+		r.mass = 1
+	}
+	if _, ok := e.properties["rotation"]; ok {
+		r.rotationDeg = float32(e.properties.float("rotation", 0))
+	} else {
+		r.rotationDeg = float32(g.random.Intn(360))
+	}
+	g.rocks = append(g.rocks, r)
+}
+
+// tileEntity creates the entity for a tile from an entity tileset, placed at
+// the given world position.
+func tileEntity(ts *tileset, id, x, y int) entity {
+	return entity{
+		typeName:   ts.tileTypes[id],
+		x:          x,
+		y:          y,
+		properties: ts.tileProperties[id],
+	}
+}
+
+// objectEntity creates the entity for an object. Tile objects inherit the type
+// and properties of their tile, the object's own type and properties take
+// precedence.
+func (l *level) objectEntity(o *object) (entity, error) {
+	e := entity{
+		typeName:   o.typeName,
+		properties: make(properties),
+	}
+	bottom := o.y + o.height
+	if o.gid != 0 {
+		ts, id, err := l.resolve(o.gid)
+		if err != nil {
+			return e, err
+		}
+		if e.typeName == "" {
+			e.typeName = ts.tileTypes[id]
+		}
+		for name, value := range ts.tileProperties[id] {
+			e.properties[name] = value
+		}
+		// Tiled positions tile objects by their bottom-left corner
+		bottom = o.y
+	}
+	for name, value := range o.properties {
+		e.properties[name] = value
+	}
+	e.x = round(o.x)
+	e.y = l.height*l.tileH - round(bottom)
+	return e, nil
+}
+
+func round(x float64) int {
+	if x < 0 {
+		return int(x - 0.5)
+	}
+	return int(x + 0.5)
+}
@@ -0,0 +1,63 @@
+package game
+
+import "testing"
+
+func TestObjectsArePlacedAsEntities(t *testing.T) {
+	const tmx = `<map orientation="orthogonal" width="10" height="5" tilewidth="160" tileheight="160">
+ <tileset firstgid="1" name="objects" tilewidth="160" tileheight="160">
+  <properties>
+   <property name="entities" type="bool" value="true"/>
+  </properties>
+  <image source="object_tiles.png" width="640" height="320"/>
+  <tile id="4" type="rock"/>
+ </tileset>
+ <objectgroup name="entities">
+  <object id="1" type="player" x="100" y="200" width="50" height="150">
+   <properties>
+    <property name="facing" value="right"/>
+   </properties>
+  </object>
+  <object id="2" class="gate" x="800" y="480" width="160" height="320"/>
+  <object id="3" gid="5" x="400" y="800" width="160" height="160">
+   <properties>
+    <property name="rotation" type="float" value="45"/>
+    <property name="mass" type="float" value="2"/>
+   </properties>
+  </object>
+  <object id="4" name="just a note" x="0" y="0" width="10" height="10"/>
+ </objectgroup>
+</map>`
+
+	l, err := decodeLevel([]byte(tmx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &game{rockHitBox: Rectangle{X: 10, Y: 0, W: 120, H: 120}}
+	for _, o := range l.objects {
+		if o.typeName == "" && o.gid == 0 {
+			continue
+		}
+		e, err := l.objectEntity(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.placeEntity(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the map is 800 pixels high, y goes up in the game
+	if g.cavemanX != 100 || g.cavemanY != 450 || !g.cavemanFacesRight {
+		t.Errorf("player at %v,%v facing right %v", g.cavemanX, g.cavemanY, g.cavemanFacesRight)
+	}
+	if g.exitX != 800 || g.exitY != 0 || g.exitFacesRight {
+		t.Errorf("gate at %v,%v facing right %v", g.exitX, g.exitY, g.exitFacesRight)
+	}
+	if len(g.rocks) != 1 {
+		t.Fatalf("want 1 rock but have %v", len(g.rocks))
+	}
+	r := g.rocks[0]
+	if r.X != 410 || r.Y != 0 || r.rotationDeg != 45 || r.mass != 2 {
+		t.Errorf("rock at %v,%v rotated %v with mass %v", r.X, r.Y, r.rotationDeg, r.mass)
+	}
+}
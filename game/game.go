@@ -3,49 +3,129 @@ package game
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"strconv"
-	"strings"
 
 	"github.com/gonutz/ld36/log"
-	"github.com/gonutz/tiled"
 )
 
-const (
-	objPlayerLeft = iota
-	objPlayerRight
-	objGateLeft
-	objGateRight
-	objRock
-)
+// UpdatesPerSecond is the rate that the game's physics are tuned for, all
+// speeds are given in pixels per update. Frontends should call Update this many
+// times per second, independent of the display's refresh rate.
+const UpdatesPerSecond = 60
 
 type Game interface {
+	// Update advances the simulation by one step without drawing anything.
+	Update([]InputEvent)
+	// Draw renders the current state and does not change it, it may be called
+	// any number of times between two updates.
+	Draw()
+	// DrawInterpolated is like Draw but moves the caveman, rocks and camera
+	// between their positions before and after the last Update. t goes from 0
+	// (previous positions) to 1 (current positions, same as Draw).
+	DrawInterpolated(t float32)
+	// DrawList returns what DrawInterpolated(t) draws, sorted by layer. The
+	// list is only valid until the next call.
+	DrawList(t float32) *DrawList
+	// Frame is Update followed by Draw.
 	Frame([]InputEvent)
+	// SetScreenSize tells the game the window size in pixels. The game keeps
+	// showing the same part of the world in any size, see VirtualWidth.
 	SetScreenSize(width, height int)
+	// QuitRequested is true once the player chose to quit in a menu, the
+	// frontend should then close the game.
+	QuitRequested() bool
 }
 
 type Resources interface {
 	LoadImage(id string) Image
 	LoadSound(id string) Sound
 	LoadFile(id string) []byte
+	// SetMasterVolume scales all sounds, 0 is silent and 1 is full volume.
+	SetMasterVolume(volume float32)
+	// SetMuted silences all sounds while muted is true, they keep playing in
+	// the background.
+	SetMuted(muted bool)
 }
 
 type DrawOptions struct {
-	FlipX             bool
-	Transparency      float32
+	// FlipX and FlipY mirror the image at its vertical and horizontal center
+	// lines.
+	FlipX, FlipY bool
+	Transparency float32
+	// CenterRotationDeg rotates the image around the pivot.
 	CenterRotationDeg float32
+	// PivotX and PivotY are the pivot's offset from the image's center in
+	// unscaled image pixels, x goes right and y goes up. The zero pivot is
+	// the center.
+	PivotX, PivotY float32
+	// ScaleX and ScaleY stretch the image away from its bottom-left corner,
+	// 0 is the same as 1.
+	ScaleX, ScaleY float32
+	// Tint is multiplied with the image's colors, the zero Color leaves them
+	// unchanged.
+	Tint Color
+}
+
+// Color has red, green, blue and alpha components from 0 to 1.
+type Color struct {
+	R, G, B, A float32
+}
+
+// White is the color that does not change an image when it is used as a tint.
+var White = Color{1, 1, 1, 1}
+
+// Scale returns the options' scale factors with 0 replaced by 1.
+func (o DrawOptions) Scale() (x, y float32) {
+	x, y = o.ScaleX, o.ScaleY
+	if x == 0 {
+		x = 1
+	}
+	if y == 0 {
+		y = 1
+	}
+	return
+}
+
+// Color returns the options' tint with the transparency applied to its alpha.
+// The zero tint is white.
+func (o DrawOptions) Color() Color {
+	c := o.Tint
+	if c == (Color{}) {
+		c = White
+	}
+	c.A *= 1 - o.Transparency
+	return c
 }
 
 type Image interface {
 	DrawAt(x, y int)
 	DrawAtEx(x, y int, options DrawOptions)
 	DrawRectAt(x, y int, source Rectangle)
+	DrawRectAtEx(x, y int, source Rectangle, options DrawOptions)
 	Size() (width, height int)
 }
 
 type Sound interface {
-	Play()
-	PlayLooping()
+	// Play starts the sound from the beginning. Each call starts a new
+	// instance, instances that were started before keep playing.
+	Play() SoundInstance
+	// PlayLooping plays the sound over and over until the instance is stopped.
+	PlayLooping() SoundInstance
+}
+
+// SoundInstance is one playback of a Sound.
+type SoundInstance interface {
+	// Stop ends the playback for good, it cannot be resumed.
+	Stop()
+	Pause()
+	Resume()
+	// SetVolume changes the volume of this instance, 0 is silent and 1 is full
+	// volume.
+	SetVolume(volume float32)
+	// IsPlaying is false after the instance ended or was stopped or paused.
+	IsPlaying() bool
 }
 
 type Rectangle struct {
@@ -56,24 +136,58 @@ func (r Rectangle) overlaps(s Rectangle) bool {
 	return r.X+r.W > s.X && r.Y+r.H > s.Y && s.X+s.W > r.X && s.Y+s.H > r.Y
 }
 
-func New(resources Resources) Game {
-	f := &gameFrame{
-		resources: resources,
-	}
+// New starts the game at the title screen. The player's progress is loaded
+// from the store and saved back to it whenever it changes. If the store is nil,
+// the progress is not kept and only the first level is unlocked.
+func New(resources Resources, store ProgressStore) Game {
+	f := &gameFrame{resources: resources, store: store}
+	f.init()
+	f.loadProgress()
+	f.screens = []screen{newTitleMenu(f)}
+	return f
+}
+
+// NewAtLevel starts the game right in the level with the given index, without
+// the title screen. No progress is loaded or saved and all levels are
+// unlocked.
+func NewAtLevel(resources Resources, levelIndex int) Game {
+	f := &gameFrame{resources: resources}
 	f.init()
+	f.progress.UnlockedLevels = f.info.LevelCount
+	f.startLevel(levelIndex)
 	return f
 }
 
 type gameFrame struct {
-	game             *game
-	resources        Resources
-	screenW, screenH int
-	winImage         Image
-	info             Info
-	levelIndex       int
-	won              bool
+	// game is the current level, it is nil on the title screen before any
+	// level was started
+	game        *game
+	resources   Resources
+	viewport    viewport
+	winImage    Image
+	font        *Font
+	letterbox   Image
+	list        DrawList
+	info        Info
+	levelIndex  int
+	screens     []screen
+	quit        bool
+	store       ProgressStore
+	progress    Progress
+	music       SoundInstance
+	musicVolume float32
+	muted       bool
+	// volumeIndex is the master volume's index in masterVolumes
+	volumeIndex int
+	// pending are the events that a screen left to the screen after it, they
+	// come before the events of the next update
+	pending []InputEvent
 }
 
+// musicFadeDelta is the volume change per update when the music fades out on
+// the win screen, it takes two seconds.
+const musicFadeDelta = 1.0 / (2 * UpdatesPerSecond)
+
 func (f *gameFrame) init() {
 	data := f.resources.LoadFile("info.json")
 	err := json.NewDecoder(bytes.NewReader(data)).Decode(&f.info)
@@ -81,139 +195,105 @@ func (f *gameFrame) init() {
 		log.Fatal("unable to decode game info json file: ", err)
 	}
 
-	f.winImage = f.resources.LoadImage("win_screen")
+	f.winImage = newListImage(f.resources, &f.list, "win_screen", LayerMenu)
+	f.font = NewFont(newListImage(f.resources, &f.list, "font", LayerMenu))
+	f.letterbox = newListImage(f.resources, &f.list, "pixel", LayerLetterbox)
+	f.viewport = fitViewport(VirtualWidth, VirtualHeight)
 
 	// start background music
-	f.resources.LoadSound("back_music").PlayLooping()
-
-	f.newGame()
+	f.music = f.resources.LoadSound("back_music").PlayLooping()
+	f.musicVolume = 1
 }
 
 func (f *gameFrame) newGame() {
 	f.game = &game{
 		resources:     f.resources,
+		list:          &f.list,
 		gateGlowDelta: 0.02,
 	}
+	f.game.SetScreenSize(VirtualWidth, VirtualHeight)
 	f.game.init(f.info, f.levelIndex)
 }
 
 func (f *gameFrame) Frame(events []InputEvent) {
-	if f.won {
-		for _, e := range events {
-			if e.Key == KeyRestart && !e.Down {
-				f.won = false
-				f.levelIndex = 0
-				f.newGame()
-				events = nil
-				break
-			}
-		}
-
-		w, h := f.winImage.Size()
-		x := (f.screenW - w) / 2
-		y := (f.screenH - h) / 2
-		f.winImage.DrawAt(x, y)
-		return
-	}
-
-	for _, e := range events {
-		if e.Key == KeyRestart && !e.Down {
-			f.newGame()
-			events = nil
-			break
-		}
-	}
-
-	f.game.Frame(events)
-
-	if f.game.levelFinished() {
-		f.levelIndex++
-		if f.levelIndex >= f.info.LevelCount {
-			f.won = true
-			return
-		}
-		f.newGame()
-	}
+	f.Update(events)
+	f.Draw()
 }
 
-func (f *gameFrame) SetScreenSize(width, height int) {
-	f.screenW, f.screenH = width, height
-	f.game.SetScreenSize(width, height)
-}
-
-type camera struct {
-	offsetX, offsetY int
-	screenW, screenH int
-	worldW, worldH   int
-}
-
-func (c *camera) setWorldSize(w, h int) {
-	c.worldW, c.worldH = w, h
+func (f *gameFrame) Update(events []InputEvent) {
+	if len(f.pending) > 0 {
+		events = append(f.pending, events...)
+		f.pending = nil
+	}
+	f.top().update(events)
 }
 
-func (c *camera) setScreenSize(w, h int) {
-	c.screenW, c.screenH = w, h
+func (f *gameFrame) QuitRequested() bool {
+	return f.quit
 }
 
-func (c *camera) centerAround(x, y int) {
-	c.offsetX, c.offsetY = c.screenW/2-x, c.screenH/2-y
-	// clamp X
-	if c.offsetX > 0 {
-		c.offsetX = 0
-	}
-	minX := -(c.worldW - c.screenW)
-	if c.offsetX < minX {
-		c.offsetX = minX
-	}
-	if c.worldW < c.screenW {
-		c.offsetX = (c.screenW - c.worldW) / 2
-	}
-	// clamp Y
-	if c.offsetY > 0 {
-		c.offsetY = 0
-	}
-	minY := -(c.worldH - c.screenH)
-	if c.offsetY < minY {
-		c.offsetY = minY
+func (f *gameFrame) fadeOutMusic() {
+	if f.musicVolume == 0 {
+		return
 	}
-	if c.worldH < c.screenH {
-		c.offsetY = (c.screenH - c.worldH) / 2
+	f.musicVolume -= musicFadeDelta
+	if f.musicVolume <= 0 {
+		f.musicVolume = 0
+		f.music.Pause()
 	}
+	f.music.SetVolume(f.musicVolume)
 }
 
-func (c *camera) transformXY(x, y int) (int, int) {
-	return x + c.offsetX, y + c.offsetY
+func (f *gameFrame) Draw() {
+	f.DrawInterpolated(1)
 }
 
-type cameraImage struct {
-	Image
-	camera *camera
+func (f *gameFrame) DrawInterpolated(t float32) {
+	f.DrawList(t).Replay(f.resources)
 }
 
-func (img cameraImage) DrawAt(x, y int) {
-	img.Image.DrawAt(img.camera.transformXY(x, y))
-}
-
-func (img cameraImage) DrawAtEx(x, y int, options DrawOptions) {
-	x, y = img.camera.transformXY(x, y)
-	img.Image.DrawAtEx(x, y, options)
+// DrawList draws the top screen. Menus over a level, like the pause menu, are
+// drawn on top of it.
+func (f *gameFrame) DrawList(t float32) *DrawList {
+	f.list.Reset()
+	top := f.top()
+	if play, ok := f.screens[0].(playScreen); ok && top != f.screens[0] {
+		play.draw(t)
+	}
+	top.draw(t)
+	f.list.Sort()
+	f.viewport.apply(&f.list, f.letterbox)
+	return &f.list
 }
 
-func (img cameraImage) DrawRectAt(x, y int, source Rectangle) {
-	x, y = img.camera.transformXY(x, y)
-	img.Image.DrawRectAt(x, y, source)
+// SetScreenSize sets the window size. The game is always drawn at the virtual
+// screen size and scaled to fit the window.
+func (f *gameFrame) SetScreenSize(width, height int) {
+	f.viewport = fitViewport(width, height)
 }
 
 type game struct {
 	resources Resources
+	// list gets the draw commands of all images
+	list *DrawList
+	// random is seeded with the level index so that levels always start out
+	// the same way
+	random *rand.Rand
 
 	camera camera
+	// view is the camera used for drawing, it lies between the camera's
+	// previous and current position when drawing interpolated.
+	view camera
 
+	// updates counts the calls to update since the level started
+	updates           int
 	levelDone         bool
 	enteringGate      bool
 	cloudDisappearing bool
 	exitGlow          float32
 	cloudSound        Sound
+	// cloudPlaying is the gate sound once the caveman enters the gate
+	cloudPlaying SoundInstance
 
 	helpImage    Image
 	cavemanStand Image
@@ -224,11 +304,11 @@ type game struct {
 	gateGlowA    Image
 	gateGlowB    Image
 	gateCloud    Image
-	tiles        Image
 
 	gateGlowRatio float32
 	gateGlowDelta float32
 
+	cavemanPushing      bool
 	pushFrameIndex      int
 	nextPushFrameChange int
 
@@ -244,6 +324,16 @@ type game struct {
 	exitX, exitY   int
 	exitFacesRight bool
 
+	// these are the positions before the last update, for interpolation
+	prevCavemanX, prevCavemanY int
+	prevOffsetX, prevOffsetY   int
+	prevRocks                  []rock
+	// the caveman fades out with the gate glow from before the last update,
+	// the gate cloud with the current one, the way they were drawn when
+	// updating and drawing happened in one step
+	prevExitGlow          float32
+	prevCloudDisappearing bool
+
 	rocks []rock
 
 	leftDown  bool
@@ -258,10 +348,12 @@ type rock struct {
 	speedX      float32
 	speedY      int
 	rotationDeg float32
+	// mass makes heavier rocks harder to push, it is 1 by default
+	mass float32
 }
 
 func (r *rock) push(xDir int) {
-	acceleration := float32(0.05)
+	acceleration := 0.05 / r.mass
 	if xDir < 0 {
 		acceleration = -acceleration
 	}
@@ -274,7 +366,13 @@ func (r *rock) push(xDir int) {
 	}
 }
 
-func (r *rock) update(m *tileMap, caveman Rectangle, others []rock, myIndex int) {
+// hardLandingSpeed is the speed in pixels per update above which a falling rock
+// shakes the camera when it lands.
+const hardLandingSpeed = 20
+
+// update moves the rock and returns the speed with which it fell onto the
+// ground or another object, 0 if it did not land in this update.
+func (r *rock) update(m *tileMap, caveman Rectangle, others []rock, myIndex int) (landingSpeed int) {
 	overlapsOther := func(r Rectangle) bool {
 		for i := range others {
 			if i == myIndex {
@@ -341,14 +439,20 @@ func (r *rock) update(m *tileMap, caveman Rectangle, others []rock, myIndex int)
 		r.Y -= backoff
 	}
 	if hitMap || dy == 0 {
+		if r.speedY < 0 {
+			landingSpeed = -r.speedY
+		}
 		r.speedY = 0
 	}
+	return landingSpeed
 }
 
-func (g *game) loadImage(id string) Image {
+// loadImage returns an image that is drawn in world coordinates in the given
+// layer.
+func (g *game) loadImage(id string, layer Layer) Image {
 	return cameraImage{
-		Image:  g.resources.LoadImage(id),
-		camera: &g.camera,
+		Image:  newListImage(g.resources, g.list, id, layer),
+		camera: &g.view,
 	}
 }
 
@@ -356,133 +460,146 @@ func (g *game) init(info Info, levelIndex int) {
 	g.cavemanHitBox = info.CavemanHitBox
 	g.rockHitBox = info.RockHitBox
 
-	g.helpImage = g.resources.LoadImage("controls")
-	g.cavemanStand = g.loadImage("caveman_stand_left")
-	g.cavemanPush[0] = g.loadImage("caveman_push_left_0")
-	g.cavemanPush[1] = g.loadImage("caveman_push_left_1")
-	g.cavemanPush[2] = g.loadImage("caveman_push_left_2")
-	g.cavemanPush[3] = g.loadImage("caveman_push_left_3")
-	g.cavemanWalk[0] = g.loadImage("caveman_walk_left_0")
-	g.cavemanWalk[1] = g.loadImage("caveman_walk_left_1")
-	g.cavemanWalk[2] = g.loadImage("caveman_walk_left_2")
-	g.cavemanWalk[3] = g.loadImage("caveman_walk_left_3")
-	g.cavemanFall = g.loadImage("caveman_fall_left")
-	g.rock = g.loadImage("rock")
-	g.gateGlowA = g.loadImage("gate_a")
-	g.gateGlowB = g.loadImage("gate_b")
-	g.gateCloud = g.loadImage("gate_cloud")
-	g.tiles = g.loadImage("tiles")
+	g.helpImage = newListImage(g.resources, g.list, "controls", LayerHUD)
+	g.cavemanStand = g.loadImage("caveman_stand_left", LayerCaveman)
+	g.cavemanPush[0] = g.loadImage("caveman_push_left_0", LayerCaveman)
+	g.cavemanPush[1] = g.loadImage("caveman_push_left_1", LayerCaveman)
+	g.cavemanPush[2] = g.loadImage("caveman_push_left_2", LayerCaveman)
+	g.cavemanPush[3] = g.loadImage("caveman_push_left_3", LayerCaveman)
+	g.cavemanWalk[0] = g.loadImage("caveman_walk_left_0", LayerCaveman)
+	g.cavemanWalk[1] = g.loadImage("caveman_walk_left_1", LayerCaveman)
+	g.cavemanWalk[2] = g.loadImage("caveman_walk_left_2", LayerCaveman)
+	g.cavemanWalk[3] = g.loadImage("caveman_walk_left_3", LayerCaveman)
+	g.cavemanFall = g.loadImage("caveman_fall_left", LayerCaveman)
+	g.rock = g.loadImage("rock", LayerRocks)
+	g.gateGlowA = g.loadImage("gate_a", LayerGate)
+	g.gateGlowB = g.loadImage("gate_b", LayerGate)
+	g.gateCloud = g.loadImage("gate_cloud", LayerEffects)
 
 	g.cloudSound = g.resources.LoadSound("cloud")
 
 	levelName := "level_" + strconv.Itoa(levelIndex) + ".tmx"
-	level, err := tiled.Read(bytes.NewReader(g.resources.LoadFile(levelName)))
+	level, err := decodeLevel(g.resources.LoadFile(levelName), func(name string) ([]byte, error) {
+		return g.resources.LoadFile(name), nil
+	})
 	if err != nil {
-		log.Fatalf("unable to decode %v: ", levelName, err)
+		log.Fatalf("unable to decode %v: %v", levelName, err)
 	}
 
-	g.tileMap.setSize(level.Width, level.Height)
-	g.tileMap.tileW, g.tileMap.tileH = level.TileWidth, level.TileHeight
-	tileSheetW, tileSheetH := g.tiles.Size()
-	tileCountX := tileSheetW / g.tileMap.tileW
-	tileCountY := tileSheetH / g.tileMap.tileH
 	// make sure the rocks always start out the same way
-	rand.Seed(int64(levelIndex))
-	for i := range level.Layers {
-		if level.Layers[i].Name == "objects" {
-			objIndexOffset := 1 + tileCountX*tileCountY
-
-			text := strings.Trim(level.Layers[i].Data.Text, "\n")
-			lines := strings.Split(text, "\n")
-			for i := range lines {
-				y := len(lines) - 1 - i
-				line := strings.TrimSuffix(lines[i], ",")
-				cols := strings.Split(line, ",")
-				for x := range cols {
-					id, err := strconv.Atoi(cols[x])
-					if err != nil {
-						log.Fatalf("tile ID is not an integer: '%v' at %v,%v", cols[x], x, y)
-					}
-					if id == 0 {
-						continue
-					}
+	g.random = rand.New(rand.NewSource(int64(levelIndex)))
+	entities, problems := g.buildLevel(level)
+	for _, e := range entities {
+		if err := g.placeEntity(e); err != nil {
+			problems = append(problems, level.entityProblem(e, err.Error()))
+		}
+	}
+	for _, p := range problems {
+		log.Printf("%v: %v\n", levelName, p)
+	}
+	g.camera.setWorldSize(g.tileMap.worldSize())
+	g.camera.settings = readCameraSettings(level.properties)
 
-					id -= objIndexOffset
-					worldX, worldY := g.tileMap.toWorldXY(x, y)
+	// make sure all pieces fall down to the ground before the first real frame
+	for i := 0; i < 10; i++ {
+		g.update(nil)
+	}
+	g.updates = 0
+	g.camera.stopShaking()
+}
 
-					if id == objPlayerLeft {
-						g.cavemanFacesRight = false
-						g.cavemanX, g.cavemanY = worldX, worldY
-					}
-					if id == objPlayerRight {
-						g.cavemanFacesRight = true
-						g.cavemanX, g.cavemanY = worldX, worldY
-					}
-					if id == objGateLeft {
-						g.exitFacesRight = false
-						g.exitX, g.exitY = worldX, worldY
-					}
-					if id == objGateRight {
-						g.exitFacesRight = true
-						g.exitX, g.exitY = worldX, worldY
-					}
-					if id == objRock {
-						r := rock{
-							Rectangle: Rectangle{
-								X: worldX + g.rockHitBox.X,
-								Y: worldY + g.rockHitBox.Y,
-								W: g.rockHitBox.W,
-								H: g.rockHitBox.H,
-							},
-							rotationDeg: float32(rand.Intn(360)),
-						}
-						g.rocks = append(g.rocks, r)
-					}
+// buildLevel fills the tile map with the level's tile layers and collects the
+// entities from entity tiles and object layers without placing them yet. Tiles
+// and objects that cannot be resolved are skipped and reported.
+func (g *game) buildLevel(l *level) (entities []entity, problems []LevelProblem) {
+	g.tileMap.setSize(l.width, l.height)
+	g.tileMap.tileW, g.tileMap.tileH = l.tileW, l.tileH
+	tilesetImages := make(map[*tileset]Image)
+	for _, layer := range l.layers {
+		// tiles in collision layers are solid unless their tileset says
+		// otherwise, tiles in other layers are only decoration
+		collision := layer.properties.bool("collision", false)
+		// go through the rows from the top, like they appear in the file
+		for row := 0; row < layer.height; row++ {
+			y := l.height - 1 - row
+			for x := 0; x < layer.width; x++ {
+				gid := layer.gids[x+row*layer.width]
+				if gid == 0 {
+					continue
+				}
+				ts, id, err := l.resolve(gid)
+				if err != nil {
+					problems = append(problems, LevelProblem{
+						X:       x,
+						Y:       row,
+						Message: fmt.Sprintf("layer %q: %v", layer.name, err),
+					})
+					continue
 				}
-			}
-		}
 
-		if level.Layers[i].Name == "0" {
-			text := strings.Trim(level.Layers[i].Data.Text, "\n")
-			lines := strings.Split(text, "\n")
-			for i := range lines {
-				y := len(lines) - 1 - i
-				line := strings.TrimSuffix(lines[i], ",")
-				cols := strings.Split(line, ",")
-				for x := range cols {
-					id, err := strconv.Atoi(cols[x])
-					if err != nil {
-						log.Fatalf("tile ID is not an integer: '%v' at %v,%v", cols[x], x, y)
-					}
-					if id != 0 {
-						id--
-						tileX, tileY := id%tileCountX, id/tileCountX
-						tile := g.tileMap.tileAt(x, y)
-						tile.imageSource = Rectangle{
-							tileX * g.tileMap.tileW,
-							tileY * g.tileMap.tileH,
-							g.tileMap.tileW,
-							g.tileMap.tileH,
-						}
-						tile.isSolid = id >= 1
-					}
+				worldX, worldY := g.tileMap.toWorldXY(x, y)
+				if ts.properties.bool("entities", false) {
+					entities = append(entities, tileEntity(ts, id, worldX, worldY))
+					continue
+				}
+
+				img, ok := tilesetImages[ts]
+				if !ok {
+					img = g.loadImage(ts.imageID(), LayerTiles)
+					tilesetImages[ts] = img
+				}
+				imageW, _ := img.Size()
+				tile := g.tileMap.tileAt(x, y)
+				source := ts.sourceRect(id, imageW)
+				tile.images = append(tile.images, tileImage{
+					image:   img,
+					source:  source,
+					options: tileDrawOptions(gid),
+				})
+				if source.W > g.tileMap.imageSize {
+					g.tileMap.imageSize = source.W
+				}
+				if source.H > g.tileMap.imageSize {
+					g.tileMap.imageSize = source.H
+				}
+				if collision && ts.tileProperties[id].bool("solid", true) {
+					tile.isSolid = true
 				}
 			}
 		}
 	}
-	g.camera.setWorldSize(g.tileMap.worldSize())
 
-	// make sure all pieces fall down to the ground before the first real frame
-	for i := 0; i < 10; i++ {
-		g.Frame(nil)
+	for _, o := range l.objects {
+		if o.typeName == "" && o.gid == 0 {
+			// objects without a type are only notes for the level designer
+			continue
+		}
+		e, err := l.objectEntity(o)
+		if err != nil {
+			problems = append(problems, LevelProblem{
+				X:       int(o.x) / l.tileW,
+				Y:       int(o.y) / l.tileH,
+				Message: fmt.Sprintf("object %v (%q) in layer %q: %v", o.id, o.name, o.layerName, err),
+			})
+			continue
+		}
+		entities = append(entities, e)
 	}
+
+	return entities, problems
 }
 
 func (g *game) SetScreenSize(width, height int) {
 	g.camera.setScreenSize(width, height)
 }
 
-func (g *game) Frame(events []InputEvent) {
+func (g *game) update(events []InputEvent) {
+	g.updates++
+	g.prevCavemanX, g.prevCavemanY = g.cavemanX, g.cavemanY
+	g.prevOffsetX, g.prevOffsetY = g.camera.offsetX, g.camera.offsetY
+	g.prevRocks = append(g.prevRocks[:0], g.rocks...)
+	g.prevExitGlow, g.prevCloudDisappearing = g.exitGlow, g.cloudDisappearing
+
 	// handle events
 	for _, e := range events {
 		switch e.Key {
@@ -508,10 +625,13 @@ func (g *game) Frame(events []InputEvent) {
 		g.cavemanHitBox.H,
 	}
 	for i := range g.rocks {
-		g.rocks[i].update(&g.tileMap, cavemanBounds, g.rocks, i)
+		landingSpeed := g.rocks[i].update(&g.tileMap, cavemanBounds, g.rocks, i)
+		if landingSpeed > hardLandingSpeed {
+			g.camera.startShaking(float64(landingSpeed - hardLandingSpeed))
+		}
 	}
 
-	cavemanPushing := false
+	g.cavemanPushing = false
 
 	const speed = 7
 	var cavemanDx int
@@ -545,7 +665,7 @@ func (g *game) Frame(events []InputEvent) {
 	if dx != 0 {
 		newDx, hitRock, rock := g.moveCavemanInX(cavemanRect, dx)
 		if hitRock && g.cavemanIsOnGround {
-			cavemanPushing = true
+			g.cavemanPushing = true
 			rock.push(dx)
 		}
 		dx = newDx
@@ -566,23 +686,21 @@ func (g *game) Frame(events []InputEvent) {
 	cavemanRect.Y += dy
 
 	cavemanCenterX := cavemanRect.X + cavemanRect.W/2
-	exitMinX, exitMaxX := g.exitX-100, g.exitX-20
-	if g.exitFacesRight {
-		w, _ := g.gateGlowA.Size()
-		exitMinX, exitMaxX = g.exitX+w+20, g.exitX+w+100
-	}
+	gateW, _ := g.gateGlowA.Size()
+	exitMinX, exitMaxX := g.gateEntryZone(gateW)
 	if !g.enteringGate &&
 		cavemanRect.Y == g.exitY &&
 		cavemanCenterX > exitMinX && cavemanCenterX < exitMaxX {
 		g.enteringGate = true
-		g.cloudSound.Play()
+		g.cloudPlaying = g.cloudSound.Play()
 	}
 
 	g.cavemanX = cavemanRect.X - g.cavemanHitBox.X
 	g.cavemanY = cavemanRect.Y - g.cavemanHitBox.Y
-	g.camera.centerAround(
+	g.camera.follow(
 		g.cavemanX+cavemanW/2,
 		g.cavemanY+cavemanH/2,
+		g.cavemanFacesRight,
 	)
 	if g.cavemanIsOnGround {
 		g.cavemanSpeedY = 0
@@ -610,23 +728,77 @@ func (g *game) Frame(events []InputEvent) {
 		g.walkFrameIndex = (g.walkFrameIndex + 1) % len(g.cavemanWalk)
 	}
 
-	// render
-	var empty Rectangle
-	for y := 0; y < g.tileMap.height; y++ {
-		for x := 0; x < g.tileMap.width; x++ {
-			tile := g.tileMap.tileAt(x, y).imageSource
-			if tile != empty {
-				x, y := g.tileMap.toWorldXY(x, y)
-				g.tiles.DrawRectAt(x, y, tile)
+	if g.enteringGate {
+		const cloudSpeed = 0.0077
+		if !g.cloudDisappearing {
+			g.exitGlow += cloudSpeed
+			if g.exitGlow > 1 {
+				g.exitGlow = 1
+				g.cloudDisappearing = true
+			}
+		} else {
+			g.exitGlow -= cloudSpeed
+			if g.exitGlow < 0 {
+				g.exitGlow = 0
+				g.levelDone = true
+			}
+		}
+	}
+}
+
+// gateEntryZone is the range of x coordinates in which the caveman's center
+// makes him enter the gate when he stands at the gate's height. gateW is the
+// width of the gate images.
+func (g *game) gateEntryZone(gateW int) (minX, maxX int) {
+	if g.exitFacesRight {
+		return g.exitX + gateW + 20, g.exitX + gateW + 100
+	}
+	return g.exitX - 100, g.exitX - 20
+}
+
+func (g *game) draw(t float32) {
+	g.view = g.camera
+	g.view.offsetX = lerp(g.prevOffsetX, g.camera.offsetX, t)
+	g.view.offsetY = lerp(g.prevOffsetY, g.camera.offsetY, t)
+
+	// only tiles near the screen are drawn, the margin is big enough for all
+	// tile images, even rotated ones
+	var noOptions DrawOptions
+	view := g.view.visibleRect()
+	margin := 2 * g.tileMap.imageSize
+	minX, minY := g.tileMap.toTileXY(view.X-margin, view.Y-margin)
+	maxX, maxY := g.tileMap.toTileXY(view.X+view.W+margin, view.Y+view.H+margin)
+	if minX < 0 {
+		minX = 0
+	}
+	if minY < 0 {
+		minY = 0
+	}
+	for y := minY; y < g.tileMap.height && y <= maxY; y++ {
+		for x := minX; x < g.tileMap.width && x <= maxX; x++ {
+			worldX, worldY := g.tileMap.toWorldXY(x, y)
+			for _, t := range g.tileMap.tileAt(x, y).images {
+				if t.options == noOptions {
+					t.image.DrawRectAt(worldX, worldY, t.source)
+				} else {
+					t.image.DrawRectAtEx(worldX, worldY, t.source, t.options)
+				}
 			}
 		}
 	}
 
 	for i := range g.rocks {
+		r := g.rocks[i]
+		if i < len(g.prevRocks) {
+			prev := g.prevRocks[i]
+			r.X = lerp(prev.X, r.X, t)
+			r.Y = lerp(prev.Y, r.Y, t)
+			r.rotationDeg = prev.rotationDeg + (r.rotationDeg-prev.rotationDeg)*t
+		}
 		g.rock.DrawAtEx(
-			g.rocks[i].X-g.rockHitBox.X,
-			g.rocks[i].Y-g.rockHitBox.Y,
-			centerRotation(g.rocks[i].rotationDeg),
+			r.X-g.rockHitBox.X,
+			r.Y-g.rockHitBox.Y,
+			centerRotation(r.rotationDeg),
 		)
 	}
 
@@ -636,35 +808,20 @@ func (g *game) Frame(events []InputEvent) {
 	caveman := g.cavemanStand
 	if !g.cavemanIsOnGround {
 		caveman = g.cavemanFall
-	} else if cavemanPushing {
+	} else if g.cavemanPushing {
 		caveman = g.cavemanPush[g.pushFrameIndex]
 	} else if xor(g.leftDown, g.rightDown) {
 		caveman = g.cavemanWalk[g.walkFrameIndex]
 	}
-	if !g.enteringGate || !g.cloudDisappearing {
+	if !g.enteringGate || !g.prevCloudDisappearing {
 		caveman.DrawAtEx(
-			g.cavemanX,
-			g.cavemanY,
-			flipX(g.cavemanFacesRight).opacity(1-g.exitGlow),
+			lerp(g.prevCavemanX, g.cavemanX, t),
+			lerp(g.prevCavemanY, g.cavemanY, t),
+			flipX(g.cavemanFacesRight).opacity(1-g.prevExitGlow),
 		)
 	}
 
 	if g.enteringGate {
-		const cloudSpeed = 0.0077
-		if !g.cloudDisappearing {
-			g.exitGlow += cloudSpeed
-			if g.exitGlow > 1 {
-				g.exitGlow = 1
-				g.cloudDisappearing = true
-			}
-		} else {
-			g.exitGlow -= cloudSpeed
-			if g.exitGlow < 0 {
-				g.exitGlow = 0
-				g.levelDone = true
-			}
-		}
-
 		x, y := g.exitX-200, g.exitY-20
 		if g.exitFacesRight {
 			w, _ := g.gateGlowA.Size()
@@ -677,6 +834,17 @@ func (g *game) Frame(events []InputEvent) {
 	g.helpImage.DrawAt(0, 0)
 }
 
+func lerp(from, to int, t float32) int {
+	if t >= 1 {
+		return to
+	}
+	d := float32(to-from) * t
+	if d < 0 {
+		return from + int(d-0.5)
+	}
+	return from + int(d+0.5)
+}
+
 func xor(a, b bool) bool {
 	return a && !b || !a && b
 }
@@ -685,6 +853,20 @@ func (g *game) levelFinished() bool {
 	return g.levelDone
 }
 
+// releaseKeys lets go of all keys, the game does not get the key events while
+// it is paused.
+func (g *game) releaseKeys() {
+	g.leftDown, g.rightDown, g.upDown = false, false, false
+}
+
+// stopSounds cancels the gate sound when the level is restarted while the
+// caveman enters the gate.
+func (g *game) stopSounds() {
+	if g.cloudPlaying != nil {
+		g.cloudPlaying.Stop()
+	}
+}
+
 func flipX(value bool) DrawOptions {
 	return DrawOptions{FlipX: value}
 }
@@ -713,14 +895,23 @@ func (o DrawOptions) centerRotation(value float32) DrawOptions {
 }
 
 type tile struct {
-	imageSource Rectangle
-	isSolid     bool
+	// images holds the tile's images of all layers, bottom to top
+	images  []tileImage
+	isSolid bool
+}
+
+type tileImage struct {
+	image   Image
+	source  Rectangle
+	options DrawOptions
 }
 
 type tileMap struct {
 	width, height int
 	tileW, tileH  int
 	tiles         []tile
+	// imageSize is the largest width or height of all tile images
+	imageSize int
 }
 
 func (m *tileMap) setSize(w, h int) {
@@ -736,6 +927,19 @@ func (m *tileMap) toTileY(worldY int) int {
 	return worldY / m.tileH
 }
 
+// toTileXY is like toTileX and toTileY but rounds down for negative world
+// coordinates as well.
+func (m *tileMap) toTileXY(worldX, worldY int) (tileX, tileY int) {
+	return floorDiv(worldX, m.tileW), floorDiv(worldY, m.tileH)
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
 func (m *tileMap) toWorldX(tileX int) int {
 	return tileX * m.tileW
 }
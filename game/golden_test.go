@@ -0,0 +1,308 @@
+package game
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run
+//
+//	go test ./game -update
+//
+// to rewrite the golden files after an intentional change to the game.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// levelCount is the number of rsc/level_N.tmx files in the repository, new
+// levels are tested without changing the tests.
+var levelCount = countLevels()
+
+func countLevels() int {
+	paths, err := filepath.Glob(filepath.Join("..", "rsc", "level_*.tmx"))
+	if err != nil {
+		panic(err)
+	}
+	return len(paths)
+}
+
+// testInfo replaces the info.json that make_assets generates from the .xcf
+// files. The hit boxes and sizes in testImageSizes are the ones that it
+// computes from the files in rsc, the physics depend on them.
+var testInfo = fmt.Sprintf(`{
+	"CavemanHitBox": {"X": 72, "Y": 6, "W": 56, "H": 175},
+	"RockHitBox": {"X": 7, "Y": 7, "W": 146, "H": 147},
+	"GateWidth": 75,
+	"LevelCount": %d
+}`, levelCount)
+
+var testImageSizes = map[string][2]int{
+	"controls":            {640, 46},
+	"win_screen":          {640, 480},
+	"caveman_stand_left":  {201, 185},
+	"caveman_fall_left":   {201, 185},
+	"caveman_push_left_0": {201, 185},
+	"caveman_push_left_1": {201, 185},
+	"caveman_push_left_2": {201, 185},
+	"caveman_push_left_3": {201, 185},
+	"caveman_walk_left_0": {201, 185},
+	"caveman_walk_left_1": {201, 185},
+	"caveman_walk_left_2": {201, 185},
+	"caveman_walk_left_3": {201, 185},
+	"rock":                {160, 160},
+	"gate_a":              {75, 247},
+	"gate_b":              {75, 247},
+	"gate_cloud":          {261, 260},
+	"tiles":               {480, 480},
+	"font":                {128, 84},
+	"pixel":               {1, 1},
+}
+
+// step holds the given keys for a number of frames. Keys that are held in the
+// previous step but not in this one are released.
+type step struct {
+	keys   []Key
+	frames int
+}
+
+type script struct {
+	name  string
+	steps []step
+}
+
+var scripts = []script{
+	{"idle", []step{
+		{nil, 1},
+		{nil, 60},
+	}},
+	{"walk_left", []step{
+		{[]Key{KeyLeft}, 30},
+		{[]Key{KeyLeft}, 60},
+		{nil, 20},
+	}},
+	{"walk_right", []step{
+		{[]Key{KeyRight}, 30},
+		{[]Key{KeyRight}, 60},
+		{nil, 20},
+	}},
+	{"jump_right", []step{
+		{[]Key{KeyRight, KeyUp}, 10},
+		{[]Key{KeyRight}, 30},
+		{[]Key{KeyRight, KeyUp}, 40},
+		{nil, 30},
+	}},
+	// this jumps over the pits and through the gate in the first level
+	{"hop_left", []step{
+		{[]Key{KeyLeft}, 14},
+		{[]Key{KeyLeft, KeyUp}, 200},
+		{nil, 60},
+		{nil, 300},
+		{[]Key{KeyConfirm}, 1},
+		{nil, 20},
+	}},
+	{"restart", []step{
+		{[]Key{KeyRight}, 40},
+		{[]Key{KeyRestart}, 1},
+		{nil, 1},
+		{nil, 20},
+	}},
+	// this restarts the level from the pause menu
+	{"pause", []step{
+		{[]Key{KeyRight}, 40},
+		{[]Key{KeyPause}, 1},
+		{nil, 10},
+		{[]Key{KeyMenuDown}, 1},
+		{nil, 1},
+		{[]Key{KeyConfirm}, 1},
+		{nil, 20},
+	}},
+}
+
+func TestGoldenFrames(t *testing.T) {
+	for level := 0; level < levelCount; level++ {
+		for _, s := range scripts {
+			name := fmt.Sprintf("level_%d_%s", level, s.name)
+			t.Run(name, func(t *testing.T) {
+				got := runScript(t, level, s)
+				path := filepath.Join("testdata", "golden", name+".txt")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+						t.Fatal(err)
+					}
+					if err := ioutil.WriteFile(path, got, 0666); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatalf("%v (run with -update to create it)", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("draw calls differ from %v, first difference:\n%v",
+						path, firstDifference(string(want), string(got)))
+				}
+			})
+		}
+	}
+}
+
+// runScript plays the script and returns, for every step, the sounds played
+// during the step and the draw list after it.
+func runScript(t *testing.T, level int, s script) []byte {
+	res := newRecordingResources(t)
+	g := NewAtLevel(res, level)
+	g.SetScreenSize(960, 540)
+
+	held := map[Key]bool{}
+	for i, step := range s.steps {
+		var events []InputEvent
+		now := map[Key]bool{}
+		for _, k := range step.keys {
+			now[k] = true
+			if !held[k] {
+				events = append(events, InputEvent{Key: k, Down: true})
+			}
+		}
+		for _, k := range []Key{
+			KeyLeft, KeyRight, KeyUp, KeyRestart,
+			KeyMenuUp, KeyMenuDown, KeyConfirm, KeyPause,
+		} {
+			if held[k] && !now[k] {
+				events = append(events, InputEvent{Key: k, Down: false})
+			}
+		}
+		held = now
+
+		for f := 0; f < step.frames; f++ {
+			g.Update(events)
+			events = nil
+		}
+		fmt.Fprintf(&res.out, "--- step %d\n", i)
+		res.out.Write(res.sounds.Bytes())
+		g.DrawList(1).WriteTo(&res.out)
+		res.sounds.Reset()
+	}
+	return res.out.Bytes()
+}
+
+func firstDifference(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d\nwant: %s\ngot:  %s", i+1, w, g)
+		}
+	}
+	return "none"
+}
+
+type recordingResources struct {
+	t      testing.TB
+	sounds bytes.Buffer
+	out    bytes.Buffer
+}
+
+func newRecordingResources(t testing.TB) *recordingResources {
+	return &recordingResources{t: t}
+}
+
+func (r *recordingResources) LoadImage(id string) Image {
+	size, ok := testImageSizes[id]
+	if !ok {
+		r.t.Fatalf("unknown image %q", id)
+	}
+	return &testImage{w: size[0], h: size[1]}
+}
+
+func (r *recordingResources) LoadSound(id string) Sound {
+	return &recordingSound{id: id, log: &r.sounds}
+}
+
+func (r *recordingResources) SetMasterVolume(volume float32) {
+	fmt.Fprintf(&r.sounds, "master volume %s\n", formatFloat(volume))
+}
+
+func (r *recordingResources) SetMuted(muted bool) {
+	fmt.Fprintf(&r.sounds, "muted %v\n", muted)
+}
+
+func (r *recordingResources) LoadFile(id string) []byte {
+	if id == "info.json" {
+		return []byte(testInfo)
+	}
+	data, err := ioutil.ReadFile(filepath.Join("..", "rsc", id))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return data
+}
+
+// testImage only has a size, the draws are checked in the game's DrawList.
+type testImage struct {
+	w, h int
+}
+
+func (img *testImage) DrawAt(x, y int)                                              {}
+func (img *testImage) DrawAtEx(x, y int, options DrawOptions)                       {}
+func (img *testImage) DrawRectAt(x, y int, source Rectangle)                        {}
+func (img *testImage) DrawRectAtEx(x, y int, source Rectangle, options DrawOptions) {}
+func (img *testImage) Size() (int, int)                                             { return img.w, img.h }
+
+type recordingSound struct {
+	id  string
+	log *bytes.Buffer
+}
+
+func (s *recordingSound) Play() SoundInstance {
+	fmt.Fprintf(s.log, "sound %s play\n", s.id)
+	return &recordingInstance{recordingSound: s, playing: true, volume: 1}
+}
+
+func (s *recordingSound) PlayLooping() SoundInstance {
+	fmt.Fprintf(s.log, "sound %s loop\n", s.id)
+	return &recordingInstance{recordingSound: s, playing: true, volume: 1}
+}
+
+// recordingInstance logs every change to the playback. Instances never end by
+// themselves, only stopping and pausing ends them.
+type recordingInstance struct {
+	*recordingSound
+	playing, stopped bool
+	volume           float32
+}
+
+func (s *recordingInstance) Stop() {
+	fmt.Fprintf(s.log, "sound %s stop\n", s.id)
+	s.playing, s.stopped = false, true
+}
+
+func (s *recordingInstance) Pause() {
+	fmt.Fprintf(s.log, "sound %s pause\n", s.id)
+	s.playing = false
+}
+
+func (s *recordingInstance) Resume() {
+	fmt.Fprintf(s.log, "sound %s resume\n", s.id)
+	s.playing = !s.stopped
+}
+
+func (s *recordingInstance) SetVolume(volume float32) {
+	fmt.Fprintf(s.log, "sound %s volume %s\n", s.id, formatFloat(volume))
+	s.volume = volume
+}
+
+func (s *recordingInstance) IsPlaying() bool {
+	return s.playing
+}
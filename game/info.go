@@ -3,5 +3,8 @@ package game
 type Info struct {
 	CavemanHitBox Rectangle
 	RockHitBox    Rectangle
-	LevelCount    int
+	// GateWidth is the width of the gate images, the caveman enters the gate
+	// next to it.
+	GateWidth  int
+	LevelCount int
 }
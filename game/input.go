@@ -7,6 +7,13 @@ const (
 	KeyRight
 	KeyUp
 	KeyRestart
+	// KeyMenuUp and KeyMenuDown move through the menu items, they are separate
+	// from KeyUp so jumping can share a button with KeyConfirm.
+	KeyMenuUp
+	KeyMenuDown
+	KeyConfirm
+	// KeyPause opens the pause menu during a level and goes back from menus.
+	KeyPause
 )
 
 type InputEvent struct {
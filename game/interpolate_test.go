@@ -0,0 +1,112 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDrawInterpolatedMovesBetweenUpdates(t *testing.T) {
+	res := newRecordingResources(t)
+	f := NewAtLevel(res, 1).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	// the camera follows the caveman exactly so it moves in every update
+	f.game.camera.settings = cameraSettings{smoothing: 1}
+	f.Update([]InputEvent{{Key: KeyRight, Down: true}})
+	for i := 0; i < 5; i++ {
+		f.Update(nil)
+	}
+	f.game.rocks[0].speedX = 3
+
+	draw := func(t float32) []position {
+		return positions(f.DrawList(t))
+	}
+	before := draw(1)
+	// jumping moves the camera up
+	f.Update([]InputEvent{{Key: KeyUp, Down: true}})
+	after := draw(1)
+
+	f.Draw()
+	if s := fmt.Sprint(positions(&f.list)); s != fmt.Sprint(after) {
+		t.Errorf("Draw draws\n%v\nbut DrawInterpolated(1) draws\n%v", s, after)
+	}
+	if s := fmt.Sprint(draw(0)); s != fmt.Sprint(before) {
+		t.Errorf("DrawInterpolated(0) draws\n%v\nbut before the update it was\n%v", s, before)
+	}
+
+	half := draw(0.5)
+	if len(half) != len(before) || len(half) != len(after) {
+		t.Fatalf("different draw calls: %v, %v and %v", before, half, after)
+	}
+	moved := map[string]bool{}
+	for i := range half {
+		b, h, a := before[i], half[i], after[i]
+		if !between(b.x, h.x, a.x) || !between(b.y, h.y, a.y) {
+			t.Errorf("%v is at %v,%v at t=0.5 but not between %v,%v and %v,%v",
+				h.id, h.x, h.y, b.x, b.y, a.x, a.y)
+		}
+		if b.x != a.x && h.x != b.x && h.x != a.x ||
+			b.y != a.y && h.y != b.y && h.y != a.y {
+			moved[h.id] = true
+		}
+	}
+	// the tiles only move with the camera
+	for _, id := range []string{"tiles", "rock", "caveman"} {
+		if !moved[id] {
+			t.Errorf("%v was not drawn between its positions", id)
+		}
+	}
+}
+
+func TestLerp(t *testing.T) {
+	tests := []struct {
+		from, to int
+		t        float32
+		want     int
+	}{
+		{0, 10, 0, 0},
+		{0, 10, 1, 10},
+		{0, 10, 0.5, 5},
+		{0, 10, 0.26, 3},
+		{10, 0, 0.26, 7},
+		{-7, 7, 0.5, 0},
+		{3, 3, 0.5, 3},
+	}
+	for _, test := range tests {
+		if have := lerp(test.from, test.to, test.t); have != test.want {
+			t.Errorf("lerp(%v, %v, %v) = %v, want %v", test.from, test.to, test.t, have, test.want)
+		}
+	}
+}
+
+// position is where an image was drawn, all caveman images have the id
+// "caveman". Only the first tile is kept since all tiles move with the camera.
+type position struct {
+	id   string
+	x, y int
+}
+
+func positions(draws *DrawList) []position {
+	var list []position
+	tiles := false
+	for _, c := range draws.Commands {
+		p := position{id: c.Image, x: c.X, y: c.Y}
+		if strings.HasPrefix(p.id, "caveman") {
+			p.id = "caveman"
+		}
+		if p.id == "tiles" {
+			if tiles {
+				continue
+			}
+			tiles = true
+		}
+		if p.id == "tiles" || p.id == "rock" || p.id == "caveman" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func between(a, x, b int) bool {
+	return a <= x && x <= b || b <= x && x <= a
+}
@@ -0,0 +1,77 @@
+package game
+
+import "github.com/gonutz/ld36/log"
+
+// Progress is what the game remembers about the player between sessions.
+type Progress struct {
+	// UnlockedLevels is the number of levels, from the first one on, that can
+	// be chosen in the level select menu. The first level is always unlocked.
+	UnlockedLevels int
+	// Levels has a record for every level that was played, indexed by level.
+	Levels []LevelRecord
+}
+
+type LevelRecord struct {
+	// BestUpdates is the fewest updates from the start of the level until the
+	// caveman went through the gate, 0 if the level was never finished.
+	BestUpdates int
+	Restarts    int
+}
+
+// ProgressStore keeps the progress between sessions, e.g. in a file.
+type ProgressStore interface {
+	// LoadProgress returns the zero Progress if nothing was saved yet.
+	LoadProgress() (Progress, error)
+	SaveProgress(Progress) error
+}
+
+func (p *Progress) level(index int) *LevelRecord {
+	for len(p.Levels) <= index {
+		p.Levels = append(p.Levels, LevelRecord{})
+	}
+	return &p.Levels[index]
+}
+
+// loadProgress reads the saved progress. If it cannot be read, the player
+// starts over and nothing is saved so a file that might just be from a newer
+// version of the game is not overwritten.
+func (f *gameFrame) loadProgress() {
+	if f.store != nil {
+		p, err := f.store.LoadProgress()
+		if err != nil {
+			log.Println("unable to load progress, it will not be saved: ", err)
+			f.store = nil
+		} else {
+			f.progress = p
+		}
+	}
+	if f.progress.UnlockedLevels < 1 {
+		f.progress.UnlockedLevels = 1
+	}
+}
+
+// levelFinished records the time for the level and unlocks the next one.
+func (f *gameFrame) levelFinished(index, updates int) {
+	r := f.progress.level(index)
+	if r.BestUpdates == 0 || updates < r.BestUpdates {
+		r.BestUpdates = updates
+	}
+	if f.progress.UnlockedLevels < index+2 {
+		f.progress.UnlockedLevels = index + 2
+	}
+	f.saveProgress()
+}
+
+func (f *gameFrame) levelRestarted(index int) {
+	f.progress.level(index).Restarts++
+	f.saveProgress()
+}
+
+func (f *gameFrame) saveProgress() {
+	if f.store == nil {
+		return
+	}
+	if err := f.store.SaveProgress(f.progress); err != nil {
+		log.Println("unable to save progress: ", err)
+	}
+}
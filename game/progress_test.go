@@ -0,0 +1,81 @@
+package game
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFinishedLevelIsSaved(t *testing.T) {
+	res := newRecordingResources(t)
+	s, err := Solve(res, 0, SolveOptions{Step: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryStore{}
+	f := New(res, store).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	f.Update(press(KeyConfirm))
+	for _, events := range s.Frames {
+		f.Update(events)
+	}
+	// continue to the next level and restart it
+	f.Update(press(KeyConfirm))
+	f.Update(press(KeyRestart))
+
+	if store.saves != 2 {
+		t.Errorf("want a save for the finished level and one for the restart but have %v", store.saves)
+	}
+	p := store.progress
+	if p.UnlockedLevels != 2 {
+		t.Errorf("want 2 unlocked levels but have %v", p.UnlockedLevels)
+	}
+	want := []LevelRecord{{BestUpdates: len(s.Frames)}, {Restarts: 1}}
+	if !reflect.DeepEqual(p.Levels, want) {
+		t.Errorf("want level records %+v but have %+v", want, p.Levels)
+	}
+}
+
+func TestLevelSelectShowsUnlockedLevels(t *testing.T) {
+	store := &memoryStore{progress: Progress{UnlockedLevels: 2}}
+	f := New(newRecordingResources(t), store).(*gameFrame)
+	f.Update(press(KeyMenuDown, KeyConfirm))
+
+	// two levels and the back item
+	if m, ok := f.top().(*menu); !ok || len(m.items) != 3 {
+		t.Fatalf("level select is not open with 2 levels, top screen is %#v", f.top())
+	}
+	f.Update(press(KeyMenuDown, KeyConfirm))
+	if f.levelIndex != 1 {
+		t.Errorf("want level 1 but have %v", f.levelIndex)
+	}
+}
+
+func TestProgressIsNotOverwrittenIfUnreadable(t *testing.T) {
+	store := &memoryStore{err: errors.New("version 2 is not supported")}
+	f := New(newRecordingResources(t), store).(*gameFrame)
+	f.Update(press(KeyConfirm))
+	f.Update(press(KeyRestart))
+	if store.saves != 0 {
+		t.Errorf("progress was saved %v times", store.saves)
+	}
+	if f.progress.UnlockedLevels != 1 {
+		t.Errorf("want only the first level unlocked but have %v", f.progress.UnlockedLevels)
+	}
+}
+
+type memoryStore struct {
+	progress Progress
+	err      error
+	saves    int
+}
+
+func (s *memoryStore) LoadProgress() (Progress, error) {
+	return s.progress, s.err
+}
+
+func (s *memoryStore) SaveProgress(p Progress) error {
+	s.progress = p
+	s.saves++
+	return nil
+}
@@ -0,0 +1,266 @@
+package game
+
+import "strconv"
+
+// screen is one state of the game like playing a level or a menu. The
+// gameFrame keeps a stack of screens and only the top one gets the input.
+type screen interface {
+	update(events []InputEvent)
+	draw(t float32)
+}
+
+func (f *gameFrame) top() screen {
+	return f.screens[len(f.screens)-1]
+}
+
+func (f *gameFrame) push(s screen) {
+	f.screens = append(f.screens, s)
+}
+
+func (f *gameFrame) pop() {
+	f.screens = f.screens[:len(f.screens)-1]
+}
+
+// startLevel replaces all screens with the given level.
+func (f *gameFrame) startLevel(index int) {
+	if f.game != nil {
+		f.game.stopSounds()
+	}
+	f.levelIndex = index
+	f.newGame()
+	f.screens = []screen{playScreen{f}}
+}
+
+func (f *gameFrame) restartLevel() {
+	f.levelRestarted(f.levelIndex)
+	f.startLevel(f.levelIndex)
+}
+
+// playScreen runs the current level.
+type playScreen struct {
+	f *gameFrame
+}
+
+func (s playScreen) update(events []InputEvent) {
+	f := s.f
+	for _, e := range events {
+		if e.Key == KeyPause && e.Down {
+			// keys that are released while the menu is open would stay down
+			// in the game
+			f.game.releaseKeys()
+			f.push(newPauseMenu(f))
+			return
+		}
+		if e.Key == KeyRestart && !e.Down {
+			f.restartLevel()
+			events = nil
+			break
+		}
+	}
+
+	f.game.update(events)
+
+	if f.game.levelFinished() {
+		f.levelFinished(f.levelIndex, f.game.updates)
+		f.levelIndex++
+		if f.levelIndex >= f.info.LevelCount {
+			f.screens = []screen{winScreen{f}}
+			return
+		}
+		f.push(newLevelCompleteMenu(f, f.game.updates))
+	}
+}
+
+func (s playScreen) draw(t float32) {
+	s.f.game.draw(t)
+}
+
+// winScreen is shown after the last level. The music fades out and a new game
+// starts when the player presses restart.
+type winScreen struct {
+	f *gameFrame
+}
+
+func (s winScreen) update(events []InputEvent) {
+	f := s.f
+	for _, e := range events {
+		if e.Key == KeyRestart && !e.Down {
+			f.startLevel(0)
+			f.musicVolume = 1
+			f.music.SetVolume(f.musicVolume)
+			f.music.Resume()
+			return
+		}
+	}
+	f.fadeOutMusic()
+}
+
+func (s winScreen) draw(t float32) {
+	f := s.f
+	w, h := f.winImage.Size()
+	x := (VirtualWidth - w) / 2
+	y := (VirtualHeight - h) / 2
+	f.winImage.DrawAt(x, y)
+}
+
+// menu is a list of items that the player selects with the menu keys.
+type menu struct {
+	f        *gameFrame
+	heading  string
+	items    []menuItem
+	selected int
+	// back is called when the player presses pause, nothing happens if it is
+	// nil
+	back func()
+}
+
+type menuItem struct {
+	label  string
+	action func()
+}
+
+// unselectedTransparency makes all but the selected menu item see-through.
+const unselectedTransparency = 0.6
+
+// menuSpacing is the space between menu items in pixels.
+const menuSpacing = 12
+
+// menuTextScale makes the font's pixels 3x3 screen pixels in menus.
+const menuTextScale = 3
+
+func (f *gameFrame) newMenu(heading string) *menu {
+	return &menu{f: f, heading: heading}
+}
+
+func (m *menu) add(label string, action func()) {
+	m.items = append(m.items, menuItem{label: label, action: action})
+}
+
+func (m *menu) update(events []InputEvent) {
+	for i, e := range events {
+		if !e.Down {
+			continue
+		}
+		switch e.Key {
+		case KeyMenuUp:
+			m.selected = (m.selected + len(m.items) - 1) % len(m.items)
+		case KeyMenuDown:
+			m.selected = (m.selected + 1) % len(m.items)
+		case KeyConfirm:
+			m.items[m.selected].action()
+		case KeyPause:
+			if m.back != nil {
+				m.back()
+			}
+		}
+		// the rest of the events go to the next screen's next update, e.g.
+		// keys that are pressed together with resume go to the level
+		if m.f.top() != m {
+			m.f.pending = append([]InputEvent(nil), events[i+1:]...)
+			return
+		}
+	}
+}
+
+// draw puts the heading in the upper third of the screen and the items below
+// it, all centered horizontally.
+func (m *menu) draw(t float32) {
+	f := m.f
+	options := TextOptions{Align: AlignCenter, Scale: menuTextScale}
+	f.font.DrawText(VirtualWidth/2, VirtualHeight*2/3, m.heading, options)
+
+	_, h := f.font.TextSize(m.heading, menuTextScale)
+	y := VirtualHeight/2 + h/2
+	for i, item := range m.items {
+		_, h := f.font.TextSize(item.label, menuTextScale)
+		y -= h
+		options.Transparency = 0
+		if i != m.selected {
+			options.Transparency = unselectedTransparency
+		}
+		f.font.DrawText(VirtualWidth/2, y, item.label, options)
+		y -= menuSpacing
+	}
+}
+
+func newTitleMenu(f *gameFrame) *menu {
+	m := f.newMenu("Reinventing the Wheel")
+	m.add("Start", func() { f.startLevel(0) })
+	m.add("Level select", func() { f.push(newLevelSelectMenu(f)) })
+	m.add("Options", func() { f.push(newOptionsMenu(f)) })
+	m.add("Quit", func() { f.quit = true })
+	return m
+}
+
+func newPauseMenu(f *gameFrame) *menu {
+	m := f.newMenu("Paused")
+	m.add("Resume", f.pop)
+	m.add("Restart level", f.restartLevel)
+	m.add("Level select", func() { f.push(newLevelSelectMenu(f)) })
+	m.add("Options", func() { f.push(newOptionsMenu(f)) })
+	m.add("Quit", func() { f.quit = true })
+	m.back = f.pop
+	return m
+}
+
+// newLevelCompleteMenu shows the time that the player needed for the level.
+func newLevelCompleteMenu(f *gameFrame, updates int) *menu {
+	m := f.newMenu("Level complete\n" + formatTime(updates))
+	m.add("Continue", func() { f.startLevel(f.levelIndex) })
+	return m
+}
+
+// newLevelSelectMenu lists only the unlocked levels with their best times.
+func newLevelSelectMenu(f *gameFrame) *menu {
+	m := f.newMenu("Level select")
+	for i := 0; i < f.info.LevelCount && i < f.progress.UnlockedLevels; i++ {
+		level := i
+		label := levelName(level)
+		if level < len(f.progress.Levels) && f.progress.Levels[level].BestUpdates > 0 {
+			label += "  " + formatTime(f.progress.Levels[level].BestUpdates)
+		}
+		m.add(label, func() { f.startLevel(level) })
+	}
+	m.add("Back", f.pop)
+	m.back = f.pop
+	return m
+}
+
+func levelName(index int) string {
+	return "Level " + strconv.Itoa(index+1)
+}
+
+// formatTime converts a number of updates to seconds, e.g. "7.25 s".
+func formatTime(updates int) string {
+	seconds := float64(updates) / UpdatesPerSecond
+	return strconv.FormatFloat(seconds, 'f', 2, 64) + " s"
+}
+
+// masterVolumes are the volume steps in the options menu in percent.
+var masterVolumes = []int{100, 75, 50, 25}
+
+func newOptionsMenu(f *gameFrame) *menu {
+	m := f.newMenu("Options")
+	soundLabel := func() string {
+		if f.muted {
+			return "Sound: off"
+		}
+		return "Sound: on"
+	}
+	volumeLabel := func() string {
+		return "Volume: " + strconv.Itoa(masterVolumes[f.volumeIndex]) + "%"
+	}
+	m.add(soundLabel(), func() {
+		f.muted = !f.muted
+		f.resources.SetMuted(f.muted)
+		m.items[0].label = soundLabel()
+	})
+	m.add(volumeLabel(), func() {
+		f.volumeIndex = (f.volumeIndex + 1) % len(masterVolumes)
+		f.resources.SetMasterVolume(float32(masterVolumes[f.volumeIndex]) / 100)
+		m.items[1].label = volumeLabel()
+	})
+	m.add("Back", f.pop)
+	m.back = f.pop
+	return m
+}
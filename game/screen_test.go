@@ -0,0 +1,110 @@
+package game
+
+import "testing"
+
+// press returns the events for pressing and releasing the keys.
+func press(keys ...Key) []InputEvent {
+	var events []InputEvent
+	for _, k := range keys {
+		events = append(events, InputEvent{Key: k, Down: true}, InputEvent{Key: k, Down: false})
+	}
+	return events
+}
+
+func TestTitleStartsFirstLevel(t *testing.T) {
+	f := New(newRecordingResources(t), nil).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	f.Draw()
+	if f.game != nil {
+		t.Fatal("a level was started on the title screen")
+	}
+
+	f.Update(press(KeyConfirm))
+	if _, ok := f.top().(playScreen); !ok || f.levelIndex != 0 {
+		t.Errorf("want level 0 to be played but top screen is %T at level %v", f.top(), f.levelIndex)
+	}
+}
+
+func TestQuitFromTitle(t *testing.T) {
+	f := New(newRecordingResources(t), nil).(*gameFrame)
+	// moving up from the first item wraps around to the last one, Quit
+	f.Update(press(KeyMenuUp))
+	if f.QuitRequested() {
+		t.Fatal("quit before confirming")
+	}
+	f.Update(press(KeyConfirm))
+	if !f.QuitRequested() {
+		t.Error("quit was not requested")
+	}
+}
+
+func TestLevelSelectFromPauseMenu(t *testing.T) {
+	f := NewAtLevel(newRecordingResources(t), 0).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	f.Update(press(KeyPause))
+	f.Update(press(KeyMenuDown, KeyMenuDown, KeyConfirm))
+	f.Update(press(KeyMenuDown, KeyMenuDown, KeyConfirm))
+
+	if len(f.screens) != 1 || f.levelIndex != 2 {
+		t.Errorf("want only level 2 on the screen stack but have %v screens at level %v",
+			len(f.screens), f.levelIndex)
+	}
+}
+
+func TestPauseStopsLevelAndReleasesKeys(t *testing.T) {
+	f := NewAtLevel(newRecordingResources(t), 0).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	f.Update([]InputEvent{{Key: KeyRight, Down: true}})
+	f.Update([]InputEvent{{Key: KeyPause, Down: true}})
+	x := f.game.cavemanX
+	for i := 0; i < 10; i++ {
+		f.Update(nil)
+	}
+	if f.game.cavemanX != x {
+		t.Error("the caveman moved while the game was paused")
+	}
+
+	// right is released while the menu is open, pause goes back to the level
+	f.Update([]InputEvent{{Key: KeyRight, Down: false}, {Key: KeyPause, Down: false}})
+	f.Update(press(KeyPause))
+	f.Update(nil)
+	if _, ok := f.top().(playScreen); !ok {
+		t.Fatalf("pause did not resume the level, top screen is %T", f.top())
+	}
+	if f.game.cavemanX != x {
+		t.Error("the caveman kept walking after the key was released in the menu")
+	}
+}
+
+func TestKeysPressedWithResumeGoToLevel(t *testing.T) {
+	f := NewAtLevel(newRecordingResources(t), 0).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	f.Update(press(KeyPause))
+	x := f.game.cavemanX
+	f.Update(append(press(KeyConfirm), InputEvent{Key: KeyRight, Down: true}))
+	if _, ok := f.top().(playScreen); !ok {
+		t.Fatalf("confirm did not resume the level, top screen is %T", f.top())
+	}
+	for i := 0; i < 10; i++ {
+		f.Update(nil)
+	}
+	if f.game.cavemanX <= x {
+		t.Error("right was pressed in the same update as resume but the caveman did not walk")
+	}
+}
+
+func TestOptionsChangeSound(t *testing.T) {
+	res := newRecordingResources(t)
+	f := New(res, nil).(*gameFrame)
+	res.sounds.Reset()
+	f.Update(press(KeyMenuDown, KeyMenuDown, KeyConfirm))
+	f.Update(press(KeyConfirm, KeyMenuDown, KeyConfirm))
+
+	if want := "muted true\nmaster volume 0.750\n"; res.sounds.String() != want {
+		t.Errorf("want sound changes\n%v\nbut have\n%v", want, res.sounds.String())
+	}
+	f.Update(press(KeyPause))
+	if _, ok := f.top().(*menu); !ok || len(f.screens) != 1 {
+		t.Errorf("options did not go back to the title")
+	}
+}
@@ -0,0 +1,326 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+var (
+	// ErrUnsolvable means that an exact search with Step 1 tried every state
+	// reachable from the level start and none of them gets the caveman into
+	// the gate.
+	ErrUnsolvable = errors.New("level cannot be solved")
+	// ErrNoSolution means that the search tried every state that it tells
+	// apart and none of them gets the caveman into the gate. The level might
+	// still be solvable since similar states are merged unless the search is
+	// exact, and the keys only change every Step frames.
+	ErrNoSolution = errors.New("no solution found")
+	// ErrSearchLimit means that no solution was found within the frame or
+	// state limit, there might be a longer one.
+	ErrSearchLimit = errors.New("no solution found within the search limits")
+)
+
+const (
+	// DefaultSolveMaxFrames is a minute of playing time.
+	DefaultSolveMaxFrames = 60 * UpdatesPerSecond
+	// DefaultSolveMaxStates keeps the memory that Solve uses below about a
+	// gigabyte.
+	DefaultSolveMaxStates = 2000000
+)
+
+// SolveOptions control the search in Solve. The zero value searches with the
+// defaults and changes the keys in every frame.
+type SolveOptions struct {
+	// MaxFrames limits the length of a solution, not counting the frames of
+	// the gate animation at the end. It is DefaultSolveMaxFrames if 0.
+	MaxFrames int
+	// MaxStates limits the number of states that are searched. It is
+	// DefaultSolveMaxStates if 0.
+	MaxStates int
+	// Step is the number of frames that an input is held before the solver
+	// changes it. Larger steps make the search faster but might miss tight
+	// jumps and make the solution a few frames longer.
+	Step int
+	// Exact makes the search tell apart all states that differ in anything
+	// that affects how the caveman and the rocks move.
+	// Otherwise states where the caveman and the rocks are only a few pixels
+	// apart count as the same, which makes searching levels with rocks
+	// feasible but might miss solutions.
+	Exact bool
+}
+
+// Solution is the input that gets the caveman through a level.
+type Solution struct {
+	// Frames are the events for each call to Update, starting with the first
+	// update after the level is loaded and ending with the update that
+	// finishes the level.
+	Frames [][]InputEvent
+	// GateFrame is the number of updates until the caveman enters the gate.
+	// The search is breadth first so this is the shortest solution that it
+	// can find. It is the shortest possible one only if the search is Exact
+	// and uses Step 1.
+	GateFrame int
+}
+
+// Solve runs the simulation of the given level from its start and searches
+// breadth first for a sequence of key presses that takes the caveman into the
+// gate. The resources must provide the same images and files as for the real
+// game since the sizes of images are part of the simulation.
+func Solve(resources Resources, levelIndex int, options SolveOptions) (Solution, error) {
+	if options.MaxFrames == 0 {
+		options.MaxFrames = DefaultSolveMaxFrames
+	}
+	if options.MaxStates == 0 {
+		options.MaxStates = DefaultSolveMaxStates
+	}
+	if options.Step < 1 {
+		options.Step = 1
+	}
+
+	var info Info
+	data := resources.LoadFile("info.json")
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&info); err != nil {
+		return Solution{}, err
+	}
+	// the search never draws, the list is only there so that the images can
+	// be loaded like in the game
+	g := &game{
+		resources:     resources,
+		list:          &DrawList{},
+		gateGlowDelta: 0.02,
+	}
+	g.init(info, levelIndex)
+	// the search plays the gate sound every time it reaches the gate
+	g.cloudSound = silence{}
+
+	key := g.coarseStateKey
+	if options.Exact {
+		key = g.exactStateKey
+	}
+
+	// nodes are kept for rebuilding the solution, their states are dropped
+	// once they are expanded
+	type node struct {
+		state  *solverState
+		keys   solverKeys
+		key    string
+		parent int
+		frames int
+	}
+	start := g.saveState()
+	nodes := []node{{state: start, key: key(), parent: -1}}
+	seen := map[string]bool{nodes[0].key: true}
+	for next := 0; next < len(nodes); next++ {
+		n := nodes[next]
+		nodes[next].state = nil
+		if n.frames+options.Step > options.MaxFrames {
+			return Solution{}, ErrSearchLimit
+		}
+		for _, keys := range solverInputs {
+			g.loadState(n.state)
+			n.keys.hold(g)
+			events := keys.eventsFrom(n.keys)
+			for i := 0; i < options.Step; i++ {
+				g.update(events)
+				events = nil
+				if g.enteringGate {
+					var steps []node
+					for p := next; nodes[p].parent != -1; p = nodes[p].parent {
+						steps = append(steps, nodes[p])
+					}
+					var s Solution
+					prev := solverKeys{}
+					for j := len(steps) - 1; j >= 0; j-- {
+						s.Frames = steps[j].keys.frames(prev, options.Step, s.Frames)
+						prev = steps[j].keys
+					}
+					s.Frames = keys.frames(prev, i+1, s.Frames)
+					s.GateFrame = len(s.Frames)
+					for !g.levelFinished() {
+						g.update(nil)
+						s.Frames = append(s.Frames, nil)
+					}
+					return s, nil
+				}
+			}
+
+			state := g.saveState()
+			k := key()
+			// with merged states, a slowly pushed rock might not leave its
+			// parent's state for a few steps, keep pushing as long as
+			// anything changes
+			continued := !options.Exact && k == n.key && keys == n.keys &&
+				!state.equals(n.state)
+			if !seen[k] || continued {
+				// continued states have been seen, count the nodes instead
+				if len(nodes) >= options.MaxStates {
+					return Solution{}, ErrSearchLimit
+				}
+				seen[k] = true
+				nodes = append(nodes, node{
+					state:  state,
+					keys:   keys,
+					key:    k,
+					parent: next,
+					frames: n.frames + options.Step,
+				})
+			}
+		}
+	}
+	if options.Exact && options.Step == 1 {
+		return Solution{}, ErrUnsolvable
+	}
+	return Solution{}, ErrNoSolution
+}
+
+// solverKeys are the keys that the solver holds down.
+type solverKeys struct {
+	left, right, up bool
+}
+
+// solverInputs are all useful key combinations, holding left and right
+// together does the same as holding neither.
+var solverInputs = []solverKeys{
+	{},
+	{left: true},
+	{right: true},
+	{up: true},
+	{left: true, up: true},
+	{right: true, up: true},
+}
+
+func (k solverKeys) hold(g *game) {
+	g.leftDown, g.rightDown, g.upDown = k.left, k.right, k.up
+}
+
+func (k solverKeys) eventsFrom(prev solverKeys) []InputEvent {
+	var events []InputEvent
+	if k.left != prev.left {
+		events = append(events, InputEvent{Key: KeyLeft, Down: k.left})
+	}
+	if k.right != prev.right {
+		events = append(events, InputEvent{Key: KeyRight, Down: k.right})
+	}
+	if k.up != prev.up {
+		events = append(events, InputEvent{Key: KeyUp, Down: k.up})
+	}
+	return events
+}
+
+// frames appends count frames of holding k, after holding prev, to frames.
+func (k solverKeys) frames(prev solverKeys, count int, frames [][]InputEvent) [][]InputEvent {
+	frames = append(frames, k.eventsFrom(prev))
+	for i := 1; i < count; i++ {
+		frames = append(frames, nil)
+	}
+	return frames
+}
+
+// solverState is everything in a game that affects how the caveman and the
+// rocks move. The rest, like animations and the camera, is left out.
+type solverState struct {
+	cavemanX, cavemanY int
+	cavemanSpeedY      int
+	cavemanIsOnGround  bool
+	rocks              []rock
+}
+
+func (g *game) saveState() *solverState {
+	return &solverState{
+		cavemanX:          g.cavemanX,
+		cavemanY:          g.cavemanY,
+		cavemanSpeedY:     g.cavemanSpeedY,
+		cavemanIsOnGround: g.cavemanIsOnGround,
+		rocks:             append([]rock(nil), g.rocks...),
+	}
+}
+
+func (g *game) loadState(s *solverState) {
+	g.cavemanX, g.cavemanY = s.cavemanX, s.cavemanY
+	g.cavemanSpeedY = s.cavemanSpeedY
+	g.cavemanIsOnGround = s.cavemanIsOnGround
+	g.rocks = append(g.rocks[:0], s.rocks...)
+}
+
+func (s *solverState) equals(t *solverState) bool {
+	if s.cavemanX != t.cavemanX ||
+		s.cavemanY != t.cavemanY ||
+		s.cavemanSpeedY != t.cavemanSpeedY ||
+		s.cavemanIsOnGround != t.cavemanIsOnGround {
+		return false
+	}
+	for i, r := range s.rocks {
+		u := t.rocks[i]
+		if r.X != u.X || r.Y != u.Y || r.speedX != u.speedX || r.speedY != u.speedY {
+			return false
+		}
+	}
+	return true
+}
+
+// exactStateKey identifies the game state for the solver. Animations, the
+// camera and the held keys are left out, the solver sets all keys before each
+// step.
+func (g *game) exactStateKey() string {
+	var k stateKey
+	k.write(g.cavemanX, g.cavemanY, g.cavemanSpeedY, boolToInt(g.cavemanIsOnGround))
+	for _, r := range g.rocks {
+		k.write(r.X, r.Y, int(math.Float32bits(r.speedX)), r.speedY)
+	}
+	return string(k)
+}
+
+// coarseStateKey is like exactStateKey but positions and speeds are rounded so
+// states that differ only by a few pixels count as the same, otherwise pushing
+// rocks around creates too many states to search. Rocks use a coarser grid
+// than the caveman, it only matters roughly where they are when they are used
+// as steps.
+func (g *game) coarseStateKey() string {
+	const (
+		cavemanGrid = 8
+		rockGrid    = 32
+	)
+	var k stateKey
+	k.write(
+		g.cavemanX/cavemanGrid,
+		g.cavemanY/cavemanGrid,
+		g.cavemanSpeedY,
+		boolToInt(g.cavemanIsOnGround),
+	)
+	for _, r := range g.rocks {
+		k.write(
+			r.X/rockGrid,
+			r.Y/rockGrid,
+			int(math.Floor(float64(r.speedX*2)+0.5)),
+			r.speedY,
+		)
+	}
+	return string(k)
+}
+
+type stateKey []byte
+
+func (k *stateKey) write(values ...int) {
+	for _, v := range values {
+		*k = append(*k, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type silence struct{}
+
+func (silence) Play() SoundInstance        { return silence{} }
+func (silence) PlayLooping() SoundInstance { return silence{} }
+func (silence) Stop()                      {}
+func (silence) Pause()                     {}
+func (silence) Resume()                    {}
+func (silence) SetVolume(float32)          {}
+func (silence) IsPlaying() bool            { return false }
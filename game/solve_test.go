@@ -0,0 +1,104 @@
+package game
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+)
+
+// Run
+//
+//	go test ./game -run Solvable -solve -timeout 30m
+//
+// to search solutions for all shipped levels, which takes a few minutes.
+var solve = flag.Bool("solve", false, "search solutions for all levels in rsc")
+
+func TestSolutionFinishesLevel(t *testing.T) {
+	res := newRecordingResources(t)
+	s, err := Solve(res, 0, SolveOptions{Step: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSolution(t, res, 0, s)
+}
+
+func TestShippedLevelsAreSolvable(t *testing.T) {
+	if !*solve {
+		t.Skip("run with -solve to search solutions for all levels")
+	}
+	for level := 0; level < levelCount; level++ {
+		t.Run(fmt.Sprint("level_", level), func(t *testing.T) {
+			res := newRecordingResources(t)
+			s, err := Solve(res, level, SolveOptions{Step: 8})
+			if err == ErrSearchLimit || err == ErrNoSolution {
+				// the search is not exact, this does not mean that the level
+				// cannot be solved
+				t.Skip(err)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("gate after %v frames", s.GateFrame)
+			checkSolution(t, res, level, s)
+		})
+	}
+}
+
+// checkSolution plays the solution in a new game and makes sure that it
+// finishes the level in its last frame.
+func checkSolution(t *testing.T, res Resources, level int, s Solution) {
+	g := NewAtLevel(res, level).(*gameFrame)
+	g.SetScreenSize(960, 540)
+	for i, events := range s.Frames {
+		if g.levelIndex != level {
+			t.Fatalf("level was finished after %v of %v frames", i, len(s.Frames))
+		}
+		g.Update(events)
+	}
+	if _, won := g.top().(winScreen); g.levelIndex == level && !won {
+		t.Fatalf("level is not finished after %v frames", len(s.Frames))
+	}
+}
+
+func TestWalledOffGateIsUnsolvable(t *testing.T) {
+	// the wall between the player and the gate is too high to jump over
+	const tmx = `<map orientation="orthogonal" width="8" height="4" tilewidth="160" tileheight="160">
+ <tileset firstgid="1" name="tiles" tilewidth="160" tileheight="160">
+  <image source="tiles.png" width="160" height="160"/>
+ </tileset>
+ <layer name="0" width="8" height="4">
+  <properties>
+   <property name="collision" type="bool" value="true"/>
+  </properties>
+  <data encoding="csv">
+1,0,0,1,0,0,0,1,
+1,0,0,1,0,0,0,1,
+1,0,0,1,0,0,0,1,
+1,1,1,1,1,1,1,1
+</data>
+ </layer>
+ <objectgroup name="entities">
+  <object id="1" type="player" x="160" y="320" width="160" height="160"/>
+  <object id="2" type="gate" x="960" y="160" width="160" height="320"/>
+ </objectgroup>
+</map>`
+
+	res := levelResources{newRecordingResources(t), tmx}
+	_, err := Solve(res, 0, SolveOptions{Exact: true})
+	if err != ErrUnsolvable {
+		t.Fatalf("want ErrUnsolvable but have %v", err)
+	}
+}
+
+// levelResources replaces level_0.tmx with the given level.
+type levelResources struct {
+	*recordingResources
+	level string
+}
+
+func (r levelResources) LoadFile(id string) []byte {
+	if id == "level_0.tmx" {
+		return []byte(r.level)
+	}
+	return r.recordingResources.LoadFile(id)
+}
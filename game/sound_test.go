@@ -0,0 +1,58 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestartStopsGateSound(t *testing.T) {
+	res := newRecordingResources(t)
+	s, err := Solve(res, 0, SolveOptions{Step: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewAtLevel(res, 0).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	for _, events := range s.Frames[:s.GateFrame] {
+		f.Update(events)
+	}
+	cloud := f.game.cloudPlaying
+	if cloud == nil || !cloud.IsPlaying() {
+		t.Fatal("gate sound is not playing after entering the gate")
+	}
+
+	f.Update([]InputEvent{{Key: KeyRestart, Down: true}, {Key: KeyRestart, Down: false}})
+	if cloud.IsPlaying() {
+		t.Error("gate sound is still playing after restarting")
+	}
+}
+
+func TestMusicFadesOutOnWinScreen(t *testing.T) {
+	res := newRecordingResources(t)
+	f := NewAtLevel(res, 0).(*gameFrame)
+	f.SetScreenSize(960, 540)
+	music := f.music.(*recordingInstance)
+	f.screens = []screen{winScreen{f}}
+
+	for i := 0; i < UpdatesPerSecond; i++ {
+		f.Update(nil)
+	}
+	if music.volume <= 0.4 || music.volume >= 0.6 {
+		t.Errorf("music volume is %v after one second", music.volume)
+	}
+	for i := 0; i < UpdatesPerSecond+1; i++ {
+		f.Update(nil)
+	}
+	if music.volume != 0 || music.IsPlaying() {
+		t.Errorf("music still plays at volume %v after two seconds", music.volume)
+	}
+
+	res.sounds.Reset()
+	f.Update([]InputEvent{{Key: KeyRestart, Down: true}, {Key: KeyRestart, Down: false}})
+	if music.volume != 1 || !music.IsPlaying() {
+		t.Errorf("music does not play at full volume after restarting, volume is %v", music.volume)
+	}
+	if log := res.sounds.String(); strings.Contains(log, "back_music loop") {
+		t.Errorf("restarting started the music again:\n%v", log)
+	}
+}
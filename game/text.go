@@ -0,0 +1,123 @@
+package game
+
+import "strings"
+
+// The image "font" that make_assets generates has the printable ASCII
+// characters from FontFirstChar to FontLastChar in rows of FontColumns glyphs.
+// Each glyph has FontGlyphWidth x FontGlyphHeight pixels, which includes a
+// pixel of shadow to the right and at the bottom, so the glyphs overlap a bit
+// when they are FontAdvance pixels apart.
+const (
+	FontFirstChar   = ' '
+	FontLastChar    = '~'
+	FontColumns     = 16
+	FontGlyphWidth  = 8
+	FontGlyphHeight = 14
+	FontAdvance     = 7
+)
+
+// Font draws text with the glyphs in a font image.
+type Font struct {
+	image Image
+}
+
+func NewFont(image Image) *Font {
+	return &Font{image: image}
+}
+
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+type TextOptions struct {
+	// Align places each line to the right of x, centered on x or to the left
+	// of x.
+	Align Align
+	// Scale is the size of a font pixel on screen, 0 is the same as 1. Scale
+	// by whole numbers to keep the pixels sharp.
+	Scale        float32
+	Tint         Color
+	Transparency float32
+}
+
+func (o TextOptions) scale() float32 {
+	if o.Scale == 0 {
+		return 1
+	}
+	return o.Scale
+}
+
+// TextSize returns the size of the text in pixels when drawn with the given
+// scale. Lines are separated by '\n'.
+func (f *Font) TextSize(text string, scale float32) (width, height int) {
+	if scale == 0 {
+		scale = 1
+	}
+	lines := strings.Split(text, "\n")
+	w := 0
+	for _, line := range lines {
+		if lineW := lineWidth(line); lineW > w {
+			w = lineW
+		}
+	}
+	s := float64(scale)
+	return round(float64(w) * s), round(float64(len(lines)*FontGlyphHeight) * s)
+}
+
+// lineWidth is the unscaled width of a line of text in pixels.
+func lineWidth(line string) int {
+	n := len([]rune(line))
+	if n == 0 {
+		return 0
+	}
+	return n*FontAdvance + FontGlyphWidth - FontAdvance
+}
+
+// DrawText draws the text with the bottom of its last line at y. Lines are
+// separated by '\n' and each one is aligned to x on its own. Characters that
+// are not in the font are drawn as '?'.
+func (f *Font) DrawText(x, y int, text string, options TextOptions) {
+	scale := float64(options.scale())
+	draw := DrawOptions{
+		ScaleX:       float32(scale),
+		ScaleY:       float32(scale),
+		Tint:         options.Tint,
+		Transparency: options.Transparency,
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lineY := y + round(float64((len(lines)-1-i)*FontGlyphHeight)*scale)
+		left := float64(x)
+		switch options.Align {
+		case AlignCenter:
+			left -= float64(lineWidth(line)) * scale / 2
+		case AlignRight:
+			left -= float64(lineWidth(line)) * scale
+		}
+		for j, r := range []rune(line) {
+			if r == ' ' {
+				continue
+			}
+			glyphX := round(left + float64(j*FontAdvance)*scale)
+			f.image.DrawRectAtEx(glyphX, lineY, glyphRect(r), draw)
+		}
+	}
+}
+
+// glyphRect is the character's part of the font image.
+func glyphRect(r rune) Rectangle {
+	if r < FontFirstChar || r > FontLastChar {
+		r = '?'
+	}
+	i := int(r - FontFirstChar)
+	return Rectangle{
+		X: i % FontColumns * FontGlyphWidth,
+		Y: i / FontColumns * FontGlyphHeight,
+		W: FontGlyphWidth,
+		H: FontGlyphHeight,
+	}
+}
@@ -0,0 +1,51 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextLinesAreAlignedOnTheirOwn(t *testing.T) {
+	var list DrawList
+	font := NewFont(newListImage(newRecordingResources(t), &list, "font", LayerMenu))
+	font.DrawText(100, 50, "AB\nC?ä", TextOptions{
+		Align: AlignRight,
+		Scale: 2,
+		Tint:  Color{1, 0, 0, 1},
+	})
+
+	// A and B are 15 pixels wide, the first line goes above the second one
+	options := " scale=2.000,2.000 tint=1.000,0.000,0.000,1.000"
+	want := []string{
+		"6 font 70 78 rect 8,28,8,14" + options,
+		"6 font 84 78 rect 16,28,8,14" + options,
+		"6 font 56 50 rect 24,28,8,14" + options,
+		"6 font 70 50 rect 120,14,8,14" + options,
+		// ä is not in the font
+		"6 font 84 50 rect 120,14,8,14" + options,
+	}
+	have := strings.Split(strings.TrimSpace(list.String()), "\n")
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want\n%v\nbut have\n%v", strings.Join(want, "\n"), strings.Join(have, "\n"))
+	}
+}
+
+func TestTextSizeIsTheWidestLine(t *testing.T) {
+	font := NewFont(newRecordingResources(t).LoadImage("font"))
+	for _, test := range []struct {
+		text  string
+		scale float32
+		w, h  int
+	}{
+		{"", 1, 0, 14},
+		{"Quit", 0, 29, 14},
+		{"Quit", 3, 87, 42},
+		{"Level complete\n7.20 s", 3, 297, 84},
+	} {
+		w, h := font.TextSize(test.text, test.scale)
+		if w != test.w || h != test.h {
+			t.Errorf("%q at scale %v is %vx%v, want %vx%v",
+				test.text, test.scale, w, h, test.w, test.h)
+		}
+	}
+}
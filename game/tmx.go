@@ -0,0 +1,522 @@
+package game
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gonutz/ld36/internal/zstd"
+)
+
+// These are the flags that Tiled stores in the upper bits of a tile's global
+// ID, the actual ID is in the remaining bits.
+const (
+	tmxFlipHorizontal = 0x80000000
+	tmxFlipVertical   = 0x40000000
+	tmxFlipDiagonal   = 0x20000000
+	tmxRotatedHex120  = 0x10000000
+	tmxFlagMask       = tmxFlipHorizontal | tmxFlipVertical | tmxFlipDiagonal | tmxRotatedHex120
+)
+
+// level is a decoded Tiled map. Layers of nested groups are flattened into
+// layers in drawing order. External tilesets are resolved.
+type level struct {
+	width, height int
+	tileW, tileH  int
+	properties    properties
+	tilesets      []*tileset
+	layers        []*tileLayer
+	objects       []*object
+}
+
+// object is an object from any of the map's object layers. Positions are in
+// pixels as in Tiled, with y going down.
+type object struct {
+	id            int
+	name          string
+	layerName     string
+	typeName      string
+	x, y          float64
+	width, height float64
+	// gid is the tile of a tile object (including flip flags) or 0
+	gid        uint32
+	properties properties
+}
+
+type tileLayer struct {
+	name          string
+	properties    properties
+	width, height int
+	// gids are the global tile IDs including the flip flags, row by row from
+	// the top, the way Tiled stores them
+	gids []uint32
+}
+
+type tileset struct {
+	firstGID        uint32
+	name            string
+	tileW, tileH    int
+	spacing, margin int
+	columns         int
+	tileCount       int
+	imageSource     string
+	imageW, imageH  int
+	properties      properties
+	tileProperties  map[int]properties
+	tileTypes       map[int]string
+}
+
+// imageID is the resource ID of the tileset's image, e.g. "tiles" for
+// "tiles.png".
+func (ts *tileset) imageID() string {
+	name := path.Base(strings.Replace(ts.imageSource, "\\", "/", -1))
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// sourceRect is the part of the tileset image for the tile with the given
+// local ID. If the TMX file does not say how many columns the image has, it is
+// computed from imageW which should be the actual image width.
+func (ts *tileset) sourceRect(id, imageW int) Rectangle {
+	cols := ts.columns
+	if cols <= 0 {
+		cols = (imageW - 2*ts.margin + ts.spacing) / (ts.tileW + ts.spacing)
+	}
+	if cols <= 0 {
+		cols = 1
+	}
+	return Rectangle{
+		X: ts.margin + (id%cols)*(ts.tileW+ts.spacing),
+		Y: ts.margin + (id/cols)*(ts.tileH+ts.spacing),
+		W: ts.tileW,
+		H: ts.tileH,
+	}
+}
+
+// resolve finds the tileset for the global tile ID and returns the tile's ID
+// local to that tileset. The gid must not be 0 (which means no tile).
+func (l *level) resolve(gid uint32) (ts *tileset, localID int, err error) {
+	id := gid &^ tmxFlagMask
+	for _, t := range l.tilesets {
+		if t.firstGID <= id && (ts == nil || t.firstGID > ts.firstGID) {
+			ts = t
+		}
+	}
+	if ts == nil {
+		return nil, 0, fmt.Errorf("tile ID %v does not belong to any tileset", id)
+	}
+	localID = int(id - ts.firstGID)
+	if ts.tileCount > 0 && localID >= ts.tileCount {
+		return nil, 0, fmt.Errorf("tile ID %v is outside of tileset %q", id, ts.name)
+	}
+	return ts, localID, nil
+}
+
+// tileDrawOptions converts Tiled's flip flags into a horizontal flip and a
+// rotation. Tiled first swaps x and y (diagonal flip), then flips horizontally,
+// then vertically, every combination of which can be expressed as an optional
+// horizontal flip followed by a rotation in steps of 90 degrees.
+func tileDrawOptions(gid uint32) DrawOptions {
+	h := gid&tmxFlipHorizontal != 0
+	v := gid&tmxFlipVertical != 0
+	d := gid&tmxFlipDiagonal != 0
+	switch {
+	case !d && h && !v:
+		return DrawOptions{FlipX: true}
+	case !d && !h && v:
+		return DrawOptions{FlipX: true, CenterRotationDeg: 180}
+	case !d && h && v:
+		return DrawOptions{CenterRotationDeg: 180}
+	case d && !h && !v:
+		return DrawOptions{FlipX: true, CenterRotationDeg: 270}
+	case d && h && !v:
+		return DrawOptions{CenterRotationDeg: 90}
+	case d && !h && v:
+		return DrawOptions{CenterRotationDeg: 270}
+	case d && h && v:
+		return DrawOptions{FlipX: true, CenterRotationDeg: 90}
+	}
+	return DrawOptions{}
+}
+
+type properties map[string]string
+
+func (p properties) bool(name string, def bool) bool {
+	if value, ok := p[name]; ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+func (p properties) float(name string, def float64) float64 {
+	if value, ok := p[name]; ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// decodeLevel parses a TMX file. External tilesets are loaded with loadFile,
+// relative to the map file.
+func decodeLevel(data []byte, loadFile func(name string) ([]byte, error)) (*level, error) {
+	var m tmxMap
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Orientation != "" && m.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported orientation %q, only orthogonal maps are supported", m.Orientation)
+	}
+	if m.Infinite {
+		return nil, errors.New("infinite maps are not supported")
+	}
+	if m.Width <= 0 || m.Height <= 0 || m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return nil, fmt.Errorf("invalid map size %vx%v with tile size %vx%v",
+			m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+
+	l := &level{
+		width:      m.Width,
+		height:     m.Height,
+		tileW:      m.TileWidth,
+		tileH:      m.TileHeight,
+		properties: m.Properties.toMap(),
+	}
+
+	for _, t := range m.Tilesets {
+		firstGID := t.FirstGID
+		if t.Source != "" {
+			tsx, err := loadFile(t.Source)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load tileset %v: %v", t.Source, err)
+			}
+			if err := xml.Unmarshal(tsx, &t); err != nil {
+				return nil, fmt.Errorf("unable to decode tileset %v: %v", t.Source, err)
+			}
+			t.FirstGID = firstGID
+		}
+		if t.TileWidth <= 0 || t.TileHeight <= 0 {
+			return nil, fmt.Errorf("tileset %q has invalid tile size %vx%v", t.Name, t.TileWidth, t.TileHeight)
+		}
+		ts := &tileset{
+			firstGID:       t.FirstGID,
+			name:           t.Name,
+			tileW:          t.TileWidth,
+			tileH:          t.TileHeight,
+			spacing:        t.Spacing,
+			margin:         t.Margin,
+			columns:        t.Columns,
+			tileCount:      t.TileCount,
+			imageSource:    t.Image.Source,
+			imageW:         t.Image.Width,
+			imageH:         t.Image.Height,
+			properties:     t.Properties.toMap(),
+			tileProperties: make(map[int]properties),
+			tileTypes:      make(map[int]string),
+		}
+		if ts.tileCount == 0 && ts.imageW > 0 && ts.imageH > 0 {
+			// older versions of Tiled do not store the tile count
+			cols := (ts.imageW - 2*ts.margin + ts.spacing) / (ts.tileW + ts.spacing)
+			rows := (ts.imageH - 2*ts.margin + ts.spacing) / (ts.tileH + ts.spacing)
+			ts.tileCount = cols * rows
+		}
+		for _, tile := range t.Tiles {
+			ts.tileProperties[tile.ID] = tile.Properties.toMap()
+			ts.tileTypes[tile.ID] = tile.typeName()
+		}
+		l.tilesets = append(l.tilesets, ts)
+	}
+
+	var addLayers func(g tmxGroup) error
+	addLayers = func(g tmxGroup) error {
+		for _, child := range g.Layers {
+			switch {
+			case child.layer != nil:
+				tl := child.layer
+				layer, err := tl.decode(m.Width, m.Height)
+				if err != nil {
+					return fmt.Errorf("layer %q: %v", tl.Name, err)
+				}
+				l.layers = append(l.layers, layer)
+			case child.objectGroup != nil:
+				og := child.objectGroup
+				for _, o := range og.Objects {
+					l.objects = append(l.objects, &object{
+						id:         o.ID,
+						name:       o.Name,
+						layerName:  og.Name,
+						typeName:   o.typeName(),
+						x:          o.X,
+						y:          o.Y,
+						width:      o.Width,
+						height:     o.Height,
+						gid:        o.GID,
+						properties: o.Properties.toMap(),
+					})
+				}
+			case child.group != nil:
+				if err := addLayers(*child.group); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := addLayers(m.tmxGroup); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+type tmxMap struct {
+	Orientation string        `xml:"orientation,attr"`
+	Width       int           `xml:"width,attr"`
+	Height      int           `xml:"height,attr"`
+	TileWidth   int           `xml:"tilewidth,attr"`
+	TileHeight  int           `xml:"tileheight,attr"`
+	Infinite    bool          `xml:"infinite,attr"`
+	Properties  tmxProperties `xml:"properties"`
+	Tilesets    []tmxTileset  `xml:"tileset"`
+	tmxGroup
+}
+
+// tmxGroup holds the layers of the map itself or of a group layer in the order
+// in which they appear in the file, which is the drawing order.
+type tmxGroup struct {
+	Layers []tmxGroupChild `xml:",any"`
+}
+
+// tmxGroupChild is one of the elements in a group, only one of its fields is
+// set. Elements that are not layers, like image layers, leave all of them nil.
+type tmxGroupChild struct {
+	layer       *tmxLayer
+	objectGroup *tmxObjectGroup
+	group       *tmxGroup
+}
+
+func (c *tmxGroupChild) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "layer":
+		c.layer = new(tmxLayer)
+		return d.DecodeElement(c.layer, &start)
+	case "objectgroup":
+		c.objectGroup = new(tmxObjectGroup)
+		return d.DecodeElement(c.objectGroup, &start)
+	case "group":
+		c.group = new(tmxGroup)
+		return d.DecodeElement(c.group, &start)
+	}
+	return d.Skip()
+}
+
+type tmxObjectGroup struct {
+	Name    string      `xml:"name,attr"`
+	Objects []tmxObject `xml:"object"`
+}
+
+type tmxObject struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Properties tmxProperties `xml:"properties"`
+}
+
+// typeName returns the object's type, which Tiled 1.9 renamed to class.
+func (o *tmxObject) typeName() string {
+	if o.Class != "" {
+		return o.Class
+	}
+	return o.Type
+}
+
+type tmxProperties struct {
+	Properties []tmxProperty `xml:"property"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+func (p tmxProperties) toMap() properties {
+	m := make(properties)
+	for _, prop := range p.Properties {
+		value := prop.Value
+		if value == "" {
+			// multi-line strings are stored as the element's text
+			value = prop.Text
+		}
+		m[prop.Name] = value
+	}
+	return m
+}
+
+type tmxTileset struct {
+	FirstGID   uint32        `xml:"firstgid,attr"`
+	Source     string        `xml:"source,attr"`
+	Name       string        `xml:"name,attr"`
+	TileWidth  int           `xml:"tilewidth,attr"`
+	TileHeight int           `xml:"tileheight,attr"`
+	Spacing    int           `xml:"spacing,attr"`
+	Margin     int           `xml:"margin,attr"`
+	TileCount  int           `xml:"tilecount,attr"`
+	Columns    int           `xml:"columns,attr"`
+	Properties tmxProperties `xml:"properties"`
+	Image      tmxImage      `xml:"image"`
+	Tiles      []tmxTile     `xml:"tile"`
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tmxTile struct {
+	ID         int           `xml:"id,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	Properties tmxProperties `xml:"properties"`
+}
+
+func (t *tmxTile) typeName() string {
+	if t.Class != "" {
+		return t.Class
+	}
+	return t.Type
+}
+
+type tmxLayer struct {
+	Name       string        `xml:"name,attr"`
+	Width      int           `xml:"width,attr"`
+	Height     int           `xml:"height,attr"`
+	Properties tmxProperties `xml:"properties"`
+	Data       tmxData       `xml:"data"`
+}
+
+type tmxData struct {
+	Encoding    string        `xml:"encoding,attr"`
+	Compression string        `xml:"compression,attr"`
+	Tiles       []tmxDataTile `xml:"tile"`
+	Chunks      []struct{}    `xml:"chunk"`
+	Text        string        `xml:",chardata"`
+}
+
+type tmxDataTile struct {
+	GID uint32 `xml:"gid,attr"`
+}
+
+func (tl *tmxLayer) decode(mapW, mapH int) (*tileLayer, error) {
+	w, h := tl.Width, tl.Height
+	if w == 0 && h == 0 {
+		w, h = mapW, mapH
+	}
+	if len(tl.Data.Chunks) > 0 {
+		return nil, errors.New("chunked layer data (infinite maps) is not supported")
+	}
+
+	gids, err := tl.Data.decode()
+	if err != nil {
+		return nil, err
+	}
+	if len(gids) != w*h {
+		return nil, fmt.Errorf("layer has %v tiles but should have %vx%v=%v", len(gids), w, h, w*h)
+	}
+	return &tileLayer{
+		name:       tl.Name,
+		properties: tl.Properties.toMap(),
+		width:      w,
+		height:     h,
+		gids:       gids,
+	}, nil
+}
+
+func (d *tmxData) decode() ([]uint32, error) {
+	switch d.Encoding {
+	case "":
+		gids := make([]uint32, len(d.Tiles))
+		for i := range d.Tiles {
+			gids[i] = d.Tiles[i].GID
+		}
+		return gids, nil
+	case "csv":
+		return decodeCSV(d.Text)
+	case "base64":
+		return decodeBase64(d.Text, d.Compression)
+	}
+	return nil, fmt.Errorf("unknown data encoding %q", d.Encoding)
+}
+
+func decodeCSV(text string) ([]uint32, error) {
+	var gids []uint32
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for row, line := range lines {
+		line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if line == "" {
+			continue
+		}
+		for col, field := range strings.Split(line, ",") {
+			gid, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("tile ID is not an integer: '%v' in row %v, column %v", field, row, col)
+			}
+			gids = append(gids, uint32(gid))
+		}
+	}
+	return gids, nil
+}
+
+func decodeBase64(text, compression string) ([]uint32, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %v", err)
+	}
+
+	var r io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		r, err = zlib.NewReader(r)
+	case "gzip":
+		r, err = gzip.NewReader(r)
+	case "zstd":
+		r = zstd.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q, use zlib, gzip, zstd or none", compression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v data: %v", compression, err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v data: %v", compression, err)
+	}
+
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("data length %v is not a multiple of 4", len(data))
+	}
+	gids := make([]uint32, len(data)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return gids, nil
+}
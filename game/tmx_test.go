@@ -0,0 +1,162 @@
+package game
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAllLayerEncodingsDecodeTheSameTiles(t *testing.T) {
+	data, err := ioutil.ReadFile("../rsc/level_2.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvLevel, err := decodeLevel(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gids := csvLevel.layers[0].gids
+
+	raw := make([]byte, 4*len(gids))
+	for i, gid := range gids {
+		binary.LittleEndian.PutUint32(raw[4*i:], gid)
+	}
+	var zlibData, gzipData bytes.Buffer
+	z := zlib.NewWriter(&zlibData)
+	z.Write(raw)
+	z.Close()
+	g := gzip.NewWriter(&gzipData)
+	g.Write(raw)
+	g.Close()
+	var xmlTiles bytes.Buffer
+	for _, gid := range gids {
+		fmt.Fprintf(&xmlTiles, "<tile gid=\"%d\"/>\n", gid)
+	}
+
+	tests := []struct {
+		attributes string
+		data       string
+	}{
+		{`encoding="base64"`, base64.StdEncoding.EncodeToString(raw)},
+		{`encoding="base64" compression="zlib"`, base64.StdEncoding.EncodeToString(zlibData.Bytes())},
+		{`encoding="base64" compression="gzip"`, "\n" + base64.StdEncoding.EncodeToString(gzipData.Bytes()) + "\n"},
+		{`encoding="base64" compression="zstd"`, level2Zstd},
+		{``, xmlTiles.String()},
+	}
+	for _, test := range tests {
+		tmx := fmt.Sprintf(`<map orientation="orthogonal" width="%d" height="%d" tilewidth="160" tileheight="160">
+<tileset firstgid="1" source="tiles.tsx"/>
+<group name="background"><layer name="0"><data %s>%s</data></layer></group>
+</map>`, csvLevel.width, csvLevel.height, test.attributes, test.data)
+		loadFile := func(name string) ([]byte, error) {
+			if name != "tiles.tsx" {
+				t.Fatalf("unexpected tileset file %q", name)
+			}
+			return []byte(`<tileset name="tiles" tilewidth="160" tileheight="160">
+<image source="../images/tiles.png" width="480" height="480"/>
+</tileset>`), nil
+		}
+
+		l, err := decodeLevel([]byte(tmx), loadFile)
+		if err != nil {
+			t.Errorf("%s: %v", test.attributes, err)
+			continue
+		}
+		if !reflect.DeepEqual(l.layers[0].gids, gids) {
+			t.Errorf("%s: decoded tiles differ from CSV", test.attributes)
+		}
+		if id := l.tilesets[0].imageID(); id != "tiles" {
+			t.Errorf("%s: external tileset image ID is %q", test.attributes, id)
+		}
+	}
+}
+
+// level2Zstd is the first layer of level_2.tmx compressed with the zstd
+// command line tool, the standard library has no zstd compressor.
+const level2Zstd = "KLUv/WRgAoUBAHgDAAMAAAABAgEDAgMBAgMQoLD2YjC7GTRUQ5IToZQSuDnrIO/KhaWJk+ySykuCBBz9qok="
+
+func TestLayersAreInDocumentOrder(t *testing.T) {
+	const tmx = `<map orientation="orthogonal" width="1" height="1" tilewidth="160" tileheight="160">
+<tileset firstgid="1" name="tiles" tilewidth="160" tileheight="160">
+ <image source="tiles.png" width="160" height="160"/>
+</tileset>
+<layer name="A"><data encoding="csv">1</data></layer>
+<objectgroup name="objects A"><object id="1" type="player"/></objectgroup>
+<group name="group">
+ <layer name="B"><data encoding="csv">1</data></layer>
+ <imagelayer name="sky"><image source="sky.png"/></imagelayer>
+ <group name="inner">
+  <objectgroup name="objects B"><object id="2" type="rock"/></objectgroup>
+  <layer name="C"><data encoding="csv">1</data></layer>
+ </group>
+</group>
+<objectgroup name="objects C"><object id="3" type="gate"/></objectgroup>
+<layer name="D"><data encoding="csv">1</data></layer>
+</map>`
+	l, err := decodeLevel([]byte(tmx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var layers, objects []string
+	for _, layer := range l.layers {
+		layers = append(layers, layer.name)
+	}
+	for _, o := range l.objects {
+		objects = append(objects, o.layerName)
+	}
+	if want := []string{"A", "B", "C", "D"}; !reflect.DeepEqual(layers, want) {
+		t.Errorf("want layers %v but have %v", want, layers)
+	}
+	if want := []string{"objects A", "objects B", "objects C"}; !reflect.DeepEqual(objects, want) {
+		t.Errorf("want objects from %v but have %v", want, objects)
+	}
+}
+
+func TestFlippedTilesResolveToTheirTileset(t *testing.T) {
+	data, err := ioutil.ReadFile("../rsc/level_0.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := decodeLevel(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, id, err := l.resolve(tmxFlipHorizontal | tmxFlipDiagonal | 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.name != "objects_tiles" || id != 2 {
+		t.Errorf("resolved to tileset %q, tile %v", ts.name, id)
+	}
+	if _, _, err := l.resolve(100); err == nil ||
+		!strings.Contains(err.Error(), "outside of tileset") {
+		t.Errorf("want error for unknown tile ID but have %v", err)
+	}
+}
+
+func TestTileFlipFlagsBecomeFlipAndRotation(t *testing.T) {
+	tests := []struct {
+		flags uint32
+		want  DrawOptions
+	}{
+		{0, DrawOptions{}},
+		{tmxFlipHorizontal, DrawOptions{FlipX: true}},
+		{tmxFlipVertical, DrawOptions{FlipX: true, CenterRotationDeg: 180}},
+		{tmxFlipHorizontal | tmxFlipVertical, DrawOptions{CenterRotationDeg: 180}},
+		{tmxFlipDiagonal | tmxFlipHorizontal, DrawOptions{CenterRotationDeg: 90}},
+		{tmxFlipDiagonal | tmxFlipVertical, DrawOptions{CenterRotationDeg: 270}},
+	}
+	for _, test := range tests {
+		if have := tileDrawOptions(test.flags | 1); have != test.want {
+			t.Errorf("flags %X: want %v but have %v", test.flags, test.want, have)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// LevelProblem is something wrong in a level file. X and Y are the tile
+// coordinates as shown in Tiled, with y going down, or -1 if the problem is not
+// at a specific tile.
+type LevelProblem struct {
+	X, Y    int
+	Message string
+}
+
+func (p LevelProblem) Error() string {
+	if p.X < 0 || p.Y < 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%v,%v: %v", p.X, p.Y, p.Message)
+}
+
+func (l *level) entityProblem(e entity, msg string) LevelProblem {
+	return LevelProblem{
+		X:       e.x / l.tileW,
+		Y:       l.height - 1 - e.y/l.tileH,
+		Message: fmt.Sprintf("%v: %v", e.typeName, msg),
+	}
+}
+
+// ValidateLevel loads a level like the game does and returns everything that
+// would make it fail or unplayable. External tilesets are loaded with
+// loadFile. The hit boxes in info are used to check whether entities are
+// embedded in walls, if they are empty a whole tile is used instead. The same
+// goes for the gate width, which decides where the caveman enters the gate.
+func ValidateLevel(data []byte, loadFile func(name string) ([]byte, error), info Info) []LevelProblem {
+	l, err := decodeLevel(data, loadFile)
+	if err != nil {
+		return []LevelProblem{{X: -1, Y: -1, Message: err.Error()}}
+	}
+
+	g := &game{
+		resources:     tilesetSizes{l},
+		cavemanHitBox: info.CavemanHitBox,
+		rockHitBox:    info.RockHitBox,
+		random:        rand.New(rand.NewSource(0)),
+	}
+	wholeTile := Rectangle{W: l.tileW, H: l.tileH}
+	if g.cavemanHitBox.W == 0 || g.cavemanHitBox.H == 0 {
+		g.cavemanHitBox = wholeTile
+	}
+	if g.rockHitBox.W == 0 || g.rockHitBox.H == 0 {
+		g.rockHitBox = wholeTile
+	}
+
+	var problems []LevelProblem
+	for _, layer := range l.layers {
+		if layer.width != l.width || layer.height != l.height {
+			problems = append(problems, LevelProblem{
+				X: -1,
+				Y: -1,
+				Message: fmt.Sprintf("layer %q has size %vx%v but the map is %vx%v",
+					layer.name, layer.width, layer.height, l.width, l.height),
+			})
+		}
+	}
+
+	entities, buildProblems := g.buildLevel(l)
+	problems = append(problems, buildProblems...)
+
+	players, gates := 0, 0
+	for _, e := range entities {
+		switch e.typeName {
+		case "player":
+			players++
+			if players > 1 {
+				problems = append(problems, l.entityProblem(e, "duplicate player start"))
+			}
+		case "gate":
+			gates++
+			if gates > 1 {
+				problems = append(problems, l.entityProblem(e, "duplicate gate, only the last one is used"))
+			}
+		}
+		rocksBefore := len(g.rocks)
+		if err := g.placeEntity(e); err != nil {
+			problems = append(problems, l.entityProblem(e, err.Error()))
+			continue
+		}
+		if len(g.rocks) > rocksBefore && g.tileMap.overlapsSolid(g.rocks[rocksBefore].Rectangle) {
+			problems = append(problems, l.entityProblem(e, "rock is embedded in solid tiles"))
+		}
+		if e.typeName == "player" && g.tileMap.overlapsSolid(g.cavemanBounds()) {
+			problems = append(problems, l.entityProblem(e, "player starts inside solid tiles"))
+		}
+	}
+	if players == 0 {
+		problems = append(problems, LevelProblem{X: -1, Y: -1, Message: "missing player start"})
+	}
+	if gates == 0 {
+		problems = append(problems, LevelProblem{X: -1, Y: -1, Message: "missing gate"})
+	}
+	// the gate images are usually one tile wide
+	gateW := info.GateWidth
+	if gateW == 0 {
+		gateW = l.tileW
+	}
+	if players > 0 && gates > 0 && !g.gateReachable(gateW) {
+		problems = append(problems, LevelProblem{
+			X:       g.exitX / l.tileW,
+			Y:       l.height - 1 - g.exitY/l.tileH,
+			Message: "gate cannot be reached from the player start",
+		})
+	}
+
+	return problems
+}
+
+func (g *game) cavemanBounds() Rectangle {
+	return Rectangle{
+		g.cavemanX + g.cavemanHitBox.X,
+		g.cavemanY + g.cavemanHitBox.Y,
+		g.cavemanHitBox.W,
+		g.cavemanHitBox.H,
+	}
+}
+
+func (m *tileMap) overlapsSolid(r Rectangle) bool {
+	for tileY := m.toTileY(r.Y); tileY <= m.toTileY(r.Y+r.H-1); tileY++ {
+		for tileX := m.toTileX(r.X); tileX <= m.toTileX(r.X+r.W-1); tileX++ {
+			if m.tileAt(tileX, tileY).isSolid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gateReachable is a rough check on whole tiles whether the caveman can walk,
+// fall and jump from his start to the spot in front of the gate. He can jump
+// one tile high and over gaps of two tiles. Rocks can be pushed into place as
+// steps, so every rock in the level lets him climb one tile higher. This is
+// optimistic, a level that passes might still be unsolvable, but a level that
+// fails cannot be solved.
+func (g *game) gateReachable(gateW int) bool {
+	m := &g.tileMap
+	solid := func(x, y int) bool { return m.tileAt(x, y).isSolid }
+	// fall returns the height at which the caveman lands when falling down
+	// from x,y or false if he falls out of the world
+	fall := func(x, y int) (int, bool) {
+		for y > 0 && !solid(x, y-1) {
+			y--
+		}
+		return y, y > 0 || solid(x, y-1)
+	}
+
+	bounds := g.cavemanBounds()
+	startX := m.toTileX(bounds.X + bounds.W/2)
+	startY, ok := fall(startX, m.toTileY(bounds.Y))
+	if !ok {
+		return false
+	}
+
+	// this is the area checked in update for entering the gate
+	minX, maxX := g.gateEntryZone(gateW)
+	goalX := m.toTileX((minX + maxX) / 2)
+	goalY := m.toTileY(g.exitY)
+
+	maxUp := 1 + len(g.rocks)
+	const maxGap = 2
+
+	type cell struct{ x, y int }
+	visited := map[cell]bool{{startX, startY}: true}
+	queue := []cell{{startX, startY}}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c.x == goalX && c.y == goalY {
+			return true
+		}
+		for up := 0; up <= maxUp; up++ {
+			if up > 0 && solid(c.x, c.y+up) {
+				break
+			}
+			for _, dir := range []int{-1, 1} {
+				for dist := 1; dist <= maxGap+1; dist++ {
+					x := c.x + dir*dist
+					if x < 0 || x >= m.width || solid(x, c.y+up) {
+						break
+					}
+					y, ok := fall(x, c.y+up)
+					next := cell{x, y}
+					if ok && !visited[next] {
+						visited[next] = true
+						queue = append(queue, next)
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// tilesetSizes provides images of the right size for the level's tilesets so
+// the level can be built without loading any actual images.
+type tilesetSizes struct {
+	level *level
+}
+
+func (r tilesetSizes) LoadImage(id string) Image {
+	for _, ts := range r.level.tilesets {
+		if ts.imageID() == id {
+			return sizeOnlyImage{ts.imageW, ts.imageH}
+		}
+	}
+	return sizeOnlyImage{}
+}
+
+func (tilesetSizes) LoadSound(id string) Sound { return nil }
+func (tilesetSizes) LoadFile(id string) []byte { return nil }
+func (tilesetSizes) SetMasterVolume(float32)   {}
+func (tilesetSizes) SetMuted(bool)             {}
+
+type sizeOnlyImage struct {
+	width, height int
+}
+
+func (sizeOnlyImage) DrawAt(x, y int)                                              {}
+func (sizeOnlyImage) DrawAtEx(x, y int, options DrawOptions)                       {}
+func (sizeOnlyImage) DrawRectAt(x, y int, source Rectangle)                        {}
+func (sizeOnlyImage) DrawRectAtEx(x, y int, source Rectangle, options DrawOptions) {}
+func (img sizeOnlyImage) Size() (int, int)                                         { return img.width, img.height }
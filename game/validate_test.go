@@ -0,0 +1,70 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestShippedLevelsAreValid(t *testing.T) {
+	var info Info
+	if err := json.Unmarshal([]byte(testInfo), &info); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < levelCount; i++ {
+		data, err := ioutil.ReadFile(fmt.Sprintf("../rsc/level_%d.tmx", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range ValidateLevel(data, nil, info) {
+			t.Errorf("level %v: %v", i, p)
+		}
+	}
+}
+
+func TestBrokenLevelReportsProblems(t *testing.T) {
+	// the player stands inside a wall and the gate is behind a wall that is
+	// too high to jump over, there is an unknown entity and no rocks
+	const tmx = `<map orientation="orthogonal" width="6" height="4" tilewidth="160" tileheight="160">
+ <tileset firstgid="1" name="tiles" tilewidth="160" tileheight="160">
+  <image source="tiles.png" width="160" height="160"/>
+ </tileset>
+ <layer name="0" width="6" height="4">
+  <properties>
+   <property name="collision" type="bool" value="true"/>
+  </properties>
+  <data encoding="csv">
+1,0,0,1,0,0,
+1,0,0,1,0,0,
+1,0,0,1,0,0,
+1,1,1,1,1,1
+</data>
+ </layer>
+ <objectgroup name="entities">
+  <object id="1" type="player" x="0" y="320" width="160" height="160"/>
+  <object id="2" type="gate" x="800" y="160" width="160" height="320"/>
+  <object id="3" type="dragon" x="320" y="320" width="160" height="160"/>
+ </objectgroup>
+</map>`
+
+	problems := ValidateLevel([]byte(tmx), nil, Info{})
+	want := []string{
+		"0,2: player: player starts inside solid tiles",
+		"2,2: dragon: unknown entity type \"dragon\"",
+		"gate cannot be reached from the player start",
+	}
+	var have []string
+	for _, p := range problems {
+		have = append(have, p.Error())
+	}
+	if len(have) != len(want) {
+		t.Fatalf("want problems\n%v\nbut have\n%v", strings.Join(want, "\n"), strings.Join(have, "\n"))
+	}
+	for i := range want {
+		if !strings.HasSuffix(have[i], want[i]) {
+			t.Errorf("want problem %q but have %q", want[i], have[i])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package headless implements game.Resources without any window, graphics card
+// or sound device. Images are rasterized in software into an in-memory frame
+// buffer which can be inspected or saved, e.g. from tests or tools. Sounds are
+// played on a software mixer whose output can be written to a WAV file.
+package headless
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/gonutz/ld36/audio"
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/log"
+)
+
+// ReadFileFrom returns a file reader that loads resources from the given
+// directory, e.g. the rsc folder after make_assets was run.
+func ReadFileFrom(dir string) func(id string) ([]byte, error) {
+	return func(id string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, id))
+	}
+}
+
+// Resources loads images and sounds through readFile and draws into an RGBA
+// screen image. The coordinate system is the same as in the Windows frontend:
+// x goes right, y goes up and 0,0 is the bottom-left corner of the screen.
+type Resources struct {
+	readFile func(id string) ([]byte, error)
+	screen   *image.RGBA
+	images   map[string]game.Image
+	sounds   map[string]game.Sound
+	mixer    *audio.Mixer
+}
+
+func NewResources(readFile func(id string) ([]byte, error), width, height int) *Resources {
+	return &Resources{
+		readFile: readFile,
+		screen:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		images:   make(map[string]game.Image),
+		sounds:   make(map[string]game.Sound),
+		mixer:    audio.NewMixer(audio.DefaultSampleRate),
+	}
+}
+
+// Screen returns the frame buffer that all images are drawn into.
+func (r *Resources) Screen() *image.RGBA {
+	return r.screen
+}
+
+// Mixer plays all loaded sounds. Nothing advances it, call its Mix method to
+// take the output, e.g. after every update.
+func (r *Resources) Mixer() *audio.Mixer {
+	return r.mixer
+}
+
+// SetScreenSize replaces the frame buffer with a new one of the given size if
+// the size changed.
+func (r *Resources) SetScreenSize(width, height int) {
+	if r.screen.Bounds().Dx() != width || r.screen.Bounds().Dy() != height {
+		r.screen = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+}
+
+// Clear fills the whole frame buffer with the given color.
+func (r *Resources) Clear(c color.Color) {
+	draw.Draw(r.screen, r.screen.Bounds(), image.NewUniform(c), image.ZP, draw.Src)
+}
+
+func (r *Resources) LoadFile(id string) []byte {
+	data, err := r.readFile(id)
+	if err != nil {
+		log.Fatalf("unable to load file %v: %v", id, err)
+	}
+	return data
+}
+
+func (r *Resources) LoadImage(id string) game.Image {
+	if img, ok := r.images[id]; ok {
+		return img
+	}
+
+	data, err := r.readFile(id + ".png")
+	if err != nil {
+		log.Fatalf("unable to load image %v.png: %v", id, err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Fatalf("image %v.png is not a valid png: %v", id, err)
+	}
+	r.images[id] = &softImage{
+		resources: r,
+		pixels:    toNRGBASwapRedBlue(decoded),
+	}
+	return r.images[id]
+}
+
+func (r *Resources) LoadSound(id string) game.Sound {
+	if s, ok := r.sounds[id]; ok {
+		return s
+	}
+
+	data, err := r.readFile(id + ".wav")
+	if err != nil {
+		log.Fatalf("unable to load sound %v.wav: %v", id, err)
+	}
+	wave, err := audio.DecodeWav(data)
+	if err != nil {
+		log.Fatalf("unable to read wave %v: %v", id, err)
+	}
+	r.sounds[id] = r.mixer.NewSound(wave)
+	return r.sounds[id]
+}
+
+func (r *Resources) SetMasterVolume(volume float32) {
+	r.mixer.SetVolume(volume)
+}
+
+func (r *Resources) SetMuted(muted bool) {
+	r.mixer.SetMuted(muted)
+}
+
+// toNRGBASwapRedBlue undoes the red/blue swap that make_assets applies for the
+// Direct3D texture format so the frame buffer has the original colors.
+func toNRGBASwapRedBlue(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	for i := 0; i < len(nrgba.Pix); i += 4 {
+		nrgba.Pix[i], nrgba.Pix[i+2] = nrgba.Pix[i+2], nrgba.Pix[i]
+	}
+	return nrgba
+}
@@ -0,0 +1,113 @@
+package headless
+
+import (
+	"image"
+	"math"
+
+	"github.com/gonutz/ld36/game"
+)
+
+type softImage struct {
+	resources *Resources
+	pixels    *image.NRGBA
+}
+
+func (img *softImage) Size() (int, int) {
+	return img.pixels.Rect.Dx(), img.pixels.Rect.Dy()
+}
+
+func (img *softImage) DrawAt(x, y int) {
+	img.draw(x, y, img.pixels.Rect, game.DrawOptions{})
+}
+
+func (img *softImage) DrawAtEx(x, y int, options game.DrawOptions) {
+	img.draw(x, y, img.pixels.Rect, options)
+}
+
+func (img *softImage) DrawRectAt(x, y int, source game.Rectangle) {
+	r := image.Rect(source.X, source.Y, source.X+source.W, source.Y+source.H)
+	img.draw(x, y, r.Intersect(img.pixels.Rect), game.DrawOptions{})
+}
+
+func (img *softImage) DrawRectAtEx(x, y int, source game.Rectangle, options game.DrawOptions) {
+	r := image.Rect(source.X, source.Y, source.X+source.W, source.Y+source.H)
+	img.draw(x, y, r.Intersect(img.pixels.Rect), options)
+}
+
+// draw renders the source part of the image with its bottom-left corner at x,y
+// in game coordinates. Every screen pixel in the (possibly scaled and rotated)
+// target area is mapped back into the source image and the nearest texel is
+// blended over the frame buffer.
+func (img *softImage) draw(x, y int, source image.Rectangle, options game.DrawOptions) {
+	tint := options.Color()
+	if tint.A <= 0 || source.Empty() {
+		return
+	}
+	if tint.A > 1 {
+		tint.A = 1
+	}
+
+	screen := img.resources.screen
+	scaleX, scaleY := options.Scale()
+	w, h := float64(source.Dx())*float64(scaleX), float64(source.Dy())*float64(scaleY)
+	// the coordinate system for drawing goes from bottom to top
+	left, top := float64(x), float64(screen.Rect.Dy()-y)-h
+	centerX := left + w/2
+	centerY := top + h/2
+	// the pivot relative to the center, in screen pixels
+	pivotX := float64(options.PivotX * scaleX)
+	pivotY := -float64(options.PivotY * scaleY)
+
+	target := image.Rect(
+		int(math.Floor(left)), int(math.Floor(top)),
+		int(math.Ceil(left+w)), int(math.Ceil(top+h)),
+	)
+	sin, cos := 0.0, 1.0
+	if degrees := options.CenterRotationDeg; degrees != 0 {
+		sin, cos = math.Sincos(float64(degrees) / 180 * math.Pi)
+		// the image stays within the circle around the pivot that goes
+		// through its farthest corner
+		radius := int(math.Ceil(math.Hypot(w/2+math.Abs(pivotX), h/2+math.Abs(pivotY))))
+		cx, cy := int(centerX+pivotX), int(centerY+pivotY)
+		target = image.Rect(cx-radius-1, cy-radius-1, cx+radius+1, cy+radius+1)
+	}
+	target = target.Intersect(screen.Rect)
+
+	for sy := target.Min.Y; sy < target.Max.Y; sy++ {
+		for sx := target.Min.X; sx < target.Max.X; sx++ {
+			dx := float64(sx) + 0.5 - centerX - pivotX
+			dy := float64(sy) + 0.5 - centerY - pivotY
+			// rotate back around the pivot into the image's local space
+			u := cos*dx + sin*dy + pivotX
+			v := -sin*dx + cos*dy + pivotY
+			if options.FlipX {
+				u = -u
+			}
+			if options.FlipY {
+				v = -v
+			}
+			tx := int(math.Floor((u + w/2) / float64(scaleX)))
+			ty := int(math.Floor((v + h/2) / float64(scaleY)))
+			if tx < 0 || ty < 0 || tx >= source.Dx() || ty >= source.Dy() {
+				continue
+			}
+			i := img.pixels.PixOffset(source.Min.X+tx, source.Min.Y+ty)
+			blend(screen, sx, sy, img.pixels.Pix[i:i+4], tint)
+		}
+	}
+}
+
+// blend draws the non-premultiplied color c, multiplied by the tint, over the
+// premultiplied pixel at x,y.
+func blend(dest *image.RGBA, x, y int, c []uint8, tint game.Color) {
+	a := float32(c[3]) / 255 * tint.A
+	if a <= 0 {
+		return
+	}
+	i := dest.PixOffset(x, y)
+	p := dest.Pix[i : i+4]
+	for j, t := range [3]float32{tint.R, tint.G, tint.B} {
+		p[j] = uint8(float32(c[j])*t*a + float32(p[j])*(1-a) + 0.5)
+	}
+	p[3] = uint8(255*a + float32(p[3])*(1-a) + 0.5)
+}
@@ -0,0 +1,97 @@
+package headless
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/gonutz/ld36/game"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	white = color.RGBA{255, 255, 255, 255}
+	black = color.RGBA{0, 0, 0, 255}
+)
+
+func TestDrawOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		// image has the pixels of the image, row by row from the top
+		image   [][]color.RGBA
+		x, y    int
+		options game.DrawOptions
+		// want is the screen after drawing on black, row by row from the top
+		want [][]color.RGBA
+	}{
+		{
+			name:    "scale",
+			image:   [][]color.RGBA{{red, green}},
+			x:       1,
+			options: game.DrawOptions{ScaleX: 2, ScaleY: 2},
+			want: [][]color.RGBA{
+				{black, red, red, green, green},
+				{black, red, red, green, green},
+			},
+		},
+		{
+			name:    "flip y",
+			image:   [][]color.RGBA{{red}, {green}},
+			options: game.DrawOptions{FlipY: true},
+			want:    [][]color.RGBA{{green}, {red}},
+		},
+		{
+			name:    "rotate around pivot",
+			image:   [][]color.RGBA{{red}},
+			options: game.DrawOptions{CenterRotationDeg: 180, PivotX: 1},
+			want:    [][]color.RGBA{{black, black, red}},
+		},
+		{
+			name:    "tint",
+			image:   [][]color.RGBA{{white, red}},
+			options: game.DrawOptions{Tint: game.Color{G: 1, A: 1}},
+			want:    [][]color.RGBA{{green, black}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, h := len(test.want[0]), len(test.want)
+			r := NewResources(pngFile(test.image), w, h)
+			r.Clear(black)
+			r.LoadImage("test").DrawAtEx(test.x, test.y, test.options)
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					if have := r.Screen().RGBAAt(x, y); have != test.want[y][x] {
+						t.Errorf("pixel %d,%d is %v, want %v", x, y, have, test.want[y][x])
+					}
+				}
+			}
+		})
+	}
+}
+
+// pngFile returns a file reader with the pixels as test.png. The red and blue
+// channels are swapped like in the files from make_assets.
+func pngFile(pixels [][]color.RGBA) func(id string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, len(pixels[0]), len(pixels)))
+	for y, row := range pixels {
+		for x, c := range row {
+			c.R, c.B = c.B, c.R
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return func(id string) ([]byte, error) {
+		if id != "test.png" {
+			return nil, errors.New("unknown file " + id)
+		}
+		return buf.Bytes(), nil
+	}
+}
@@ -0,0 +1,265 @@
+// Package input translates physical inputs like keyboard keys and gamepad
+// buttons into game.InputEvents. Which input triggers which game key is read
+// from a bindings file that players can edit, each game key can have any
+// number of inputs.
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/gonutz/ld36/game"
+)
+
+// Input names a physical key, button or stick direction. Letter and digit keys
+// are named after their letter or digit, e.g. "A" or "7", function keys are
+// "F1" to "F12".
+type Input string
+
+// Keyboard keys without a letter or digit.
+const (
+	KeyLeft    Input = "Left"
+	KeyRight   Input = "Right"
+	KeyUp      Input = "Up"
+	KeyDown    Input = "Down"
+	KeySpace   Input = "Space"
+	KeyEnter   Input = "Enter"
+	KeyEscape  Input = "Escape"
+	KeyShift   Input = "Shift"
+	KeyControl Input = "Control"
+)
+
+// Gamepad buttons and the directions of the left analog stick.
+const (
+	PadA          Input = "PadA"
+	PadB          Input = "PadB"
+	PadX          Input = "PadX"
+	PadY          Input = "PadY"
+	PadStart      Input = "PadStart"
+	PadBack       Input = "PadBack"
+	PadLeft       Input = "PadLeft"
+	PadRight      Input = "PadRight"
+	PadUp         Input = "PadUp"
+	PadDown       Input = "PadDown"
+	PadStickLeft  Input = "PadStickLeft"
+	PadStickRight Input = "PadStickRight"
+	PadStickUp    Input = "PadStickUp"
+	PadStickDown  Input = "PadStickDown"
+)
+
+var padInputs = []Input{
+	PadA, PadB, PadX, PadY, PadStart, PadBack,
+	PadLeft, PadRight, PadUp, PadDown,
+	PadStickLeft, PadStickRight, PadStickUp, PadStickDown,
+}
+
+// known reports whether in is one of the inputs that frontends can report.
+func (in Input) known() bool {
+	switch in {
+	case KeyLeft, KeyRight, KeyUp, KeyDown, KeySpace, KeyEnter, KeyEscape, KeyShift, KeyControl:
+		return true
+	}
+	for _, pad := range padInputs {
+		if in == pad {
+			return true
+		}
+	}
+	if len(in) == 1 {
+		c := in[0]
+		return 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+	}
+	var f int
+	if n, _ := fmt.Sscanf(string(in), "F%d", &f); n == 1 {
+		return 1 <= f && f <= 12 && in == Input(fmt.Sprint("F", f))
+	}
+	return false
+}
+
+// Bindings maps every game key to the inputs that press it.
+type Bindings map[game.Key][]Input
+
+// actionNames are the names of the game keys in bindings files.
+var actionNames = map[game.Key]string{
+	game.KeyLeft:     "left",
+	game.KeyRight:    "right",
+	game.KeyUp:       "jump",
+	game.KeyRestart:  "restart",
+	game.KeyMenuUp:   "menu_up",
+	game.KeyMenuDown: "menu_down",
+	game.KeyConfirm:  "confirm",
+	game.KeyPause:    "pause",
+}
+
+// DefaultBindings are the arrow keys, WASD and an Xbox controller.
+func DefaultBindings() Bindings {
+	return Bindings{
+		game.KeyLeft:     {KeyLeft, "A", PadLeft, PadStickLeft},
+		game.KeyRight:    {KeyRight, "D", PadRight, PadStickRight},
+		game.KeyUp:       {KeyUp, "W", KeySpace, PadA},
+		game.KeyRestart:  {"F2", PadBack},
+		game.KeyMenuUp:   {KeyUp, "W", PadUp, PadStickUp},
+		game.KeyMenuDown: {KeyDown, "S", PadDown, PadStickDown},
+		game.KeyConfirm:  {KeyEnter, KeySpace, PadA},
+		game.KeyPause:    {KeyEscape, PadStart},
+	}
+}
+
+// ReadBindings reads a JSON object that lists the inputs for each action, e.g.
+//
+//	{"left": ["Left", "A"], "right": ["Right", "D"], "jump": ["Up", "PadA"]}
+//
+// Actions that are missing get their default inputs, this way files from older
+// versions of the game still work when new actions are added.
+func ReadBindings(r io.Reader) (Bindings, error) {
+	var file map[string][]Input
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	b := DefaultBindings()
+	for name, inputs := range file {
+		key, ok := actionKey(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+		for _, in := range inputs {
+			if !in.known() {
+				return nil, fmt.Errorf("unknown input %q for %v", in, name)
+			}
+		}
+		b[key] = inputs
+	}
+	return b, nil
+}
+
+func actionKey(name string) (game.Key, bool) {
+	for key, n := range actionNames {
+		if n == name {
+			return key, true
+		}
+	}
+	return 0, false
+}
+
+// Write writes the bindings in the format that ReadBindings reads.
+func (b Bindings) Write(w io.Writer) error {
+	file := map[string][]Input{}
+	for key, inputs := range b {
+		name, ok := actionNames[key]
+		if !ok {
+			return fmt.Errorf("unknown game key %v", key)
+		}
+		file[name] = inputs
+	}
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Mapper turns input changes into game events. A game key is down while any of
+// its inputs is down, so only the first press and the last release of its
+// inputs create events.
+type Mapper struct {
+	keys    map[Input][]game.Key
+	down    map[Input]bool
+	pressed map[game.Key]int
+	// order has all bound game keys sorted so events always come in the same
+	// order
+	order []game.Key
+}
+
+func NewMapper(b Bindings) *Mapper {
+	m := &Mapper{
+		keys:    map[Input][]game.Key{},
+		down:    map[Input]bool{},
+		pressed: map[game.Key]int{},
+	}
+	for key := range b {
+		m.order = append(m.order, key)
+	}
+	sort.Slice(m.order, func(i, j int) bool { return m.order[i] < m.order[j] })
+	for _, key := range m.order {
+		for _, in := range b[key] {
+			m.keys[in] = append(m.keys[in], key)
+		}
+	}
+	return m
+}
+
+// Set changes the state of an input and returns the resulting game events.
+// Repeated presses, like the ones that keyboards send while a key is held,
+// create no events.
+func (m *Mapper) Set(in Input, down bool) []game.InputEvent {
+	return m.setAll(map[Input]bool{in: down})
+}
+
+// setAll changes several inputs at once. Released keys come before pressed
+// keys and a key that stays down because another of its inputs is pressed in
+// the same call creates no events.
+func (m *Mapper) setAll(down map[Input]bool) []game.InputEvent {
+	before := map[game.Key]bool{}
+	for _, key := range m.order {
+		before[key] = m.pressed[key] > 0
+	}
+	for in, d := range down {
+		if m.down[in] == d {
+			continue
+		}
+		m.down[in] = d
+		for _, key := range m.keys[in] {
+			if d {
+				m.pressed[key]++
+			} else {
+				m.pressed[key]--
+			}
+		}
+	}
+
+	var events []game.InputEvent
+	for _, key := range m.order {
+		if before[key] && m.pressed[key] == 0 {
+			events = append(events, game.InputEvent{Key: key, Down: false})
+		}
+	}
+	for _, key := range m.order {
+		if !before[key] && m.pressed[key] > 0 {
+			events = append(events, game.InputEvent{Key: key, Down: true})
+		}
+	}
+	return events
+}
+
+// stickThreshold is how far the analog stick must be pushed in a direction to
+// count as pressed.
+const stickThreshold = 0.5
+
+// PadState is the state of a gamepad at one point in time.
+type PadState struct {
+	// Buttons are the held buttons, e.g. PadA or PadLeft.
+	Buttons []Input
+	// StickX and StickY are the position of the left analog stick, each goes
+	// from -1 to 1 with y going up.
+	StickX, StickY float32
+}
+
+// SetPad updates all gamepad inputs at once, the stick directions count as
+// pressed once the stick is pushed at least halfway. A disconnected gamepad is
+// the zero PadState.
+func (m *Mapper) SetPad(p PadState) []game.InputEvent {
+	down := map[Input]bool{}
+	for _, in := range padInputs {
+		down[in] = false
+	}
+	down[PadStickLeft] = p.StickX <= -stickThreshold
+	down[PadStickRight] = p.StickX >= stickThreshold
+	down[PadStickDown] = p.StickY <= -stickThreshold
+	down[PadStickUp] = p.StickY >= stickThreshold
+	for _, b := range p.Buttons {
+		down[b] = true
+	}
+	return m.setAll(down)
+}
@@ -0,0 +1,120 @@
+package input
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/ld36/game"
+)
+
+func TestKeyIsHeldWhileAnyBindingIsDown(t *testing.T) {
+	m := NewMapper(Bindings{game.KeyLeft: {KeyLeft, "A"}})
+
+	steps := []struct {
+		in   Input
+		down bool
+		want []game.InputEvent
+	}{
+		{KeyLeft, true, []game.InputEvent{{Key: game.KeyLeft, Down: true}}},
+		{KeyLeft, true, nil},
+		{"A", true, nil},
+		{KeyLeft, false, nil},
+		{"A", false, []game.InputEvent{{Key: game.KeyLeft, Down: false}}},
+		{"A", false, nil},
+		{"B", true, nil},
+	}
+	for i, step := range steps {
+		if have := m.Set(step.in, step.down); !reflect.DeepEqual(have, step.want) {
+			t.Errorf("step %d: setting %v to %v gives %v, want %v",
+				i, step.in, step.down, have, step.want)
+		}
+	}
+}
+
+func TestOneInputCanPressSeveralKeys(t *testing.T) {
+	m := NewMapper(Bindings{
+		game.KeyUp:    {KeySpace},
+		game.KeyRight: {KeySpace, "D"},
+	})
+	want := []game.InputEvent{
+		{Key: game.KeyRight, Down: true},
+		{Key: game.KeyUp, Down: true},
+	}
+	if have := m.Set(KeySpace, true); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v but have %v", want, have)
+	}
+}
+
+func TestStickDirectionsArePressedHalfway(t *testing.T) {
+	m := NewMapper(Bindings{
+		game.KeyLeft: {PadLeft, PadStickLeft},
+		game.KeyUp:   {PadA},
+	})
+
+	if have := m.SetPad(PadState{StickX: -0.4}); have != nil {
+		t.Errorf("stick at -0.4 creates events %v", have)
+	}
+	want := []game.InputEvent{{Key: game.KeyLeft, Down: true}}
+	if have := m.SetPad(PadState{StickX: -0.6}); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v but have %v", want, have)
+	}
+	// holding the d-pad in the same direction keeps the key down
+	if have := m.SetPad(PadState{Buttons: []Input{PadLeft}}); have != nil {
+		t.Errorf("switching from stick to d-pad creates events %v", have)
+	}
+	want = []game.InputEvent{
+		{Key: game.KeyLeft, Down: false},
+		{Key: game.KeyUp, Down: true},
+	}
+	if have := m.SetPad(PadState{Buttons: []Input{PadA}}); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v but have %v", want, have)
+	}
+	want = []game.InputEvent{{Key: game.KeyUp, Down: false}}
+	if have := m.SetPad(PadState{}); !reflect.DeepEqual(have, want) {
+		t.Errorf("disconnecting the pad gives %v, want %v", have, want)
+	}
+}
+
+func TestWrittenBindingsAreReadBack(t *testing.T) {
+	var buf bytes.Buffer
+	if err := DefaultBindings().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadBindings(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b, DefaultBindings()) {
+		t.Errorf("want\n%v\nbut have\n%v", DefaultBindings(), b)
+	}
+}
+
+func TestMissingActionsGetDefaults(t *testing.T) {
+	b, err := ReadBindings(strings.NewReader(`{"left": ["J"], "jump": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultBindings()
+	want[game.KeyLeft] = []Input{"J"}
+	want[game.KeyUp] = []Input{}
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, b)
+	}
+}
+
+func TestInvalidBindingsAreRejected(t *testing.T) {
+	for _, file := range []string{
+		`{"left": ["Left"]`,
+		`{"walk": ["Left"]}`,
+		`{"left": ["LeftArrow"]}`,
+		`{"left": ["F13"]}`,
+		`{"left": ["F01"]}`,
+		`{"left": ["a"]}`,
+	} {
+		if _, err := ReadBindings(strings.NewReader(file)); err == nil {
+			t.Errorf("%v was read without error", file)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package input
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// xinput1_4 comes with Windows 8 and later, xinput9_1_0 with Vista and 7.
+var xInputGetState = findXInputGetState("xinput1_4.dll", "xinput9_1_0.dll")
+
+func findXInputGetState(dlls ...string) *syscall.LazyProc {
+	for _, dll := range dlls {
+		proc := syscall.NewLazyDLL(dll).NewProc("XInputGetState")
+		if proc.Find() == nil {
+			return proc
+		}
+	}
+	return nil
+}
+
+type xInputState struct {
+	packetNumber uint32
+	buttons      uint16
+	leftTrigger  byte
+	rightTrigger byte
+	thumbLX      int16
+	thumbLY      int16
+	thumbRX      int16
+	thumbRY      int16
+}
+
+var xInputButtons = []struct {
+	mask  uint16
+	input Input
+}{
+	{0x0001, PadUp},
+	{0x0002, PadDown},
+	{0x0004, PadLeft},
+	{0x0008, PadRight},
+	{0x0010, PadStart},
+	{0x0020, PadBack},
+	{0x1000, PadA},
+	{0x2000, PadB},
+	{0x4000, PadX},
+	{0x8000, PadY},
+}
+
+// ReadXInputPad returns the state of the XInput gamepad with the given index
+// from 0 to 3. It is false if that pad is not connected or XInput is not
+// installed.
+func ReadXInputPad(index int) (PadState, bool) {
+	if xInputGetState == nil {
+		return PadState{}, false
+	}
+	var state xInputState
+	result, _, _ := xInputGetState.Call(uintptr(index), uintptr(unsafe.Pointer(&state)))
+	if result != 0 {
+		return PadState{}, false
+	}
+	var p PadState
+	for _, b := range xInputButtons {
+		if state.buttons&b.mask != 0 {
+			p.Buttons = append(p.Buttons, b.input)
+		}
+	}
+	p.StickX = float32(state.thumbLX) / 32768
+	p.StickY = float32(state.thumbLY) / 32768
+	return p, true
+}
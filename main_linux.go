@@ -0,0 +1,395 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"unsafe"
+
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/gonutz/ld36/audio"
+	"github.com/gonutz/ld36/batch"
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/input"
+	"github.com/gonutz/ld36/log"
+	"github.com/gonutz/ld36/save"
+)
+
+func init() {
+	// GLFW and OpenGL must be called from the main thread
+	runtime.LockOSThread()
+}
+
+var (
+	// previousX, previousY, previousW and previousH are the window's position
+	// and size before going into full-screen
+	previousX, previousY, previousW, previousH int
+	sprites                                    = batch.New(drawQuads)
+	windowW, windowH                           int
+	events                                     []game.InputEvent
+	inputs                                     *input.Mapper
+)
+
+func main() {
+	flag.Parse()
+
+	// the log and the key bindings are next to the save file, without a
+	// config directory the log only goes to the console and the default key
+	// bindings are used, like the progress is not saved then
+	configDir, err := save.DefaultDir()
+	if err == nil {
+		err = os.MkdirAll(configDir, 0777)
+	}
+	var logFile *os.File
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to create config directory: ", err)
+		configDir = ""
+	} else if logFile, err = os.Create(filepath.Join(configDir, "log.txt")); err == nil {
+		log.Init(logFile)
+	} else {
+		fmt.Fprintln(os.Stderr, "unable to create log file: ", err)
+	}
+
+	// close the log file at the end of the program
+	defer func() {
+		if logFile != nil {
+			logFile.Close()
+		}
+	}()
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("panic: %v\nstack\n---\n%s\n---\n", err, debug.Stack())
+			os.Exit(1)
+		}
+	}()
+
+	bindings := input.DefaultBindings()
+	if configDir != "" {
+		bindings = loadBindings(filepath.Join(configDir, "bindings.json"))
+	}
+	inputs = input.NewMapper(bindings)
+
+	openAssets()
+
+	// create the window and initialize OpenGL
+	if err := glfw.Init(); err != nil {
+		log.Fatal("unable to initialize GLFW: ", err)
+	}
+	defer glfw.Terminate()
+
+	glfw.WindowHint(glfw.ContextVersionMajor, 2)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	window, err := glfw.CreateWindow(660, 500, "Reinventing the Wheel", nil, nil)
+	if err != nil {
+		log.Fatal("unable to open window: ", err)
+	}
+	window.MakeContextCurrent()
+	glfw.SwapInterval(1) // enable VSync
+	if err := gl.Init(); err != nil {
+		log.Fatal("unable to initialize OpenGL: ", err)
+	}
+	window.SetKeyCallback(handleKey)
+	window.SetFramebufferSizeCallback(func(_ *glfw.Window, width, height int) {
+		windowW, windowH = width, height
+	})
+
+	fullscreen := true
+	//fullscreen = false // NOTE toggle comment on this line for debugging
+	if fullscreen {
+		toggleFullscreen(window)
+	}
+	windowW, windowH = window.GetFramebufferSize()
+
+	soundMixer := audio.NewMixer(audio.DefaultSampleRate)
+	soundDevice, err := audio.OpenDevice(soundMixer)
+	if err != nil {
+		log.Println("unable to open the sound device: ", err)
+		muted = true
+	} else {
+		defer soundDevice.Close()
+	}
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.CULL_FACE)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.Enable(gl.TEXTURE_2D)
+	gl.TexEnvi(gl.TEXTURE_ENV, gl.TEXTURE_ENV_MODE, gl.MODULATE)
+	gl.EnableClientState(gl.VERTEX_ARRAY)
+	gl.EnableClientState(gl.TEXTURE_COORD_ARRAY)
+	gl.EnableClientState(gl.COLOR_ARRAY)
+
+	res := newGameResources(soundMixer)
+	defer res.close()
+	g, endGame := startGame(res)
+	defer endGame()
+	sim := newSimulation(g)
+	//sim.interpolate = false // NOTE toggle comment to draw only whole updates
+
+	for !window.ShouldClose() {
+		glfw.PollEvents()
+
+		// a disconnected gamepad reads as the zero state which releases all
+		// its inputs
+		events = append(events, inputs.SetPad(readGamepad(glfw.Joystick1))...)
+
+		g.SetScreenSize(windowW, windowH)
+		t := sim.advance(&events)
+		if g.QuitRequested() {
+			window.SetShouldClose(true)
+		}
+
+		// like in Direct3D, the origin is the top-left corner and y goes down
+		gl.Viewport(0, 0, int32(windowW), int32(windowH))
+		gl.MatrixMode(gl.PROJECTION)
+		gl.LoadIdentity()
+		gl.Ortho(0, float64(windowW), float64(windowH), 0, -1, 1)
+		gl.ClearColor(0, 95.0/255, 83.0/255, 1)
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+
+		g.DrawList(t).Replay(res)
+
+		sprites.Flush()
+		window.SwapBuffers()
+	}
+}
+
+// keyInputs are the inputs for keys that are not letters, digits or function
+// keys.
+var keyInputs = map[glfw.Key]input.Input{
+	glfw.KeyLeft:         input.KeyLeft,
+	glfw.KeyRight:        input.KeyRight,
+	glfw.KeyUp:           input.KeyUp,
+	glfw.KeyDown:         input.KeyDown,
+	glfw.KeySpace:        input.KeySpace,
+	glfw.KeyEnter:        input.KeyEnter,
+	glfw.KeyKPEnter:      input.KeyEnter,
+	glfw.KeyEscape:       input.KeyEscape,
+	glfw.KeyLeftShift:    input.KeyShift,
+	glfw.KeyRightShift:   input.KeyShift,
+	glfw.KeyLeftControl:  input.KeyControl,
+	glfw.KeyRightControl: input.KeyControl,
+}
+
+func keyInput(key glfw.Key) (input.Input, bool) {
+	// GLFW's letter and digit keys have their ASCII values
+	if glfw.KeyA <= key && key <= glfw.KeyZ || glfw.Key0 <= key && key <= glfw.Key9 {
+		return input.Input(rune(key)), true
+	}
+	if glfw.KeyF1 <= key && key <= glfw.KeyF12 {
+		return input.Input(fmt.Sprint("F", key-glfw.KeyF1+1)), true
+	}
+	in, ok := keyInputs[key]
+	return in, ok
+}
+
+func handleKey(window *glfw.Window, key glfw.Key, _ int, action glfw.Action, _ glfw.ModifierKey) {
+	if action == glfw.Repeat {
+		return
+	}
+	down := action == glfw.Press
+	if in, ok := keyInput(key); ok {
+		events = append(events, inputs.Set(in, down)...)
+	}
+	if key == glfw.KeyF11 && down {
+		toggleFullscreen(window)
+	}
+}
+
+// gamepadButtons maps GLFW's standard gamepad layout, which is the Xbox
+// controller's, to the pad inputs.
+var gamepadButtons = []struct {
+	button glfw.GamepadButton
+	input  input.Input
+}{
+	{glfw.ButtonA, input.PadA},
+	{glfw.ButtonB, input.PadB},
+	{glfw.ButtonX, input.PadX},
+	{glfw.ButtonY, input.PadY},
+	{glfw.ButtonStart, input.PadStart},
+	{glfw.ButtonBack, input.PadBack},
+	{glfw.ButtonDpadLeft, input.PadLeft},
+	{glfw.ButtonDpadRight, input.PadRight},
+	{glfw.ButtonDpadUp, input.PadUp},
+	{glfw.ButtonDpadDown, input.PadDown},
+}
+
+// readGamepad returns the zero state if the joystick is not connected or GLFW
+// does not know its layout.
+func readGamepad(joystick glfw.Joystick) input.PadState {
+	state := joystick.GetGamepadState()
+	if state == nil {
+		return input.PadState{}
+	}
+	var p input.PadState
+	for _, b := range gamepadButtons {
+		if state.Buttons[b.button] == glfw.Press {
+			p.Buttons = append(p.Buttons, b.input)
+		}
+	}
+	p.StickX = state.Axes[glfw.AxisLeftX]
+	// GLFW's y axis goes down
+	p.StickY = -state.Axes[glfw.AxisLeftY]
+	return p
+}
+
+func toggleFullscreen(window *glfw.Window) {
+	if window.GetMonitor() == nil {
+		// go into full-screen
+		previousX, previousY = window.GetPos()
+		previousW, previousH = window.GetSize()
+		monitor := glfw.GetPrimaryMonitor()
+		mode := monitor.GetVideoMode()
+		window.SetMonitor(monitor, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+		window.SetInputMode(glfw.CursorMode, glfw.CursorHidden)
+	} else {
+		// go into windowed mode
+		window.SetMonitor(nil, previousX, previousY, previousW, previousH, 0)
+		window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+	}
+}
+
+func newGameResources(mixer *audio.Mixer) *resources {
+	return &resources{
+		fileResources: newFileResources(mixer),
+		images:        make(map[string]game.Image),
+	}
+}
+
+type resources struct {
+	fileResources
+	textures []uint32
+	images   map[string]game.Image
+}
+
+func (r *resources) close() {
+	if len(r.textures) > 0 {
+		gl.DeleteTextures(int32(len(r.textures)), &r.textures[0])
+	}
+	r.textures = nil
+	r.images = make(map[string]game.Image)
+}
+
+// LoadImage returns sprites as parts of their atlas so that they share its
+// texture.
+func (r *resources) LoadImage(id string) game.Image {
+	return atlases.Load(id, r.loadTexture)
+}
+
+func (r *resources) loadTexture(id string) game.Image {
+	if img, ok := r.images[id]; ok {
+		return img
+	}
+
+	texture, w, h := mustLoadTexture(id)
+	r.textures = append(r.textures, texture)
+	r.images[id] = textureImage{
+		texture: texture,
+		width:   w,
+		height:  h,
+	}
+
+	log.Printf("loaded texture %v (size %vx%v)\n", id, w, h)
+
+	return r.images[id]
+}
+
+func mustLoadTexture(id string) (texture uint32, width, height int) {
+	nrgba := mustLoadImage(id)
+	width, height = nrgba.Bounds().Dx(), nrgba.Bounds().Dy()
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	// texture filter for when zooming
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(nrgba.Stride/4))
+	// the images in rsc have red and blue swapped for Direct3D, which makes
+	// them BGRA for OpenGL
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		int32(width),
+		int32(height),
+		0,
+		gl.BGRA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(nrgba.Pix),
+	)
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+	if code := gl.GetError(); code != gl.NO_ERROR {
+		log.Fatalf("unable to create texture %v: OpenGL error %v", id, code)
+	}
+	return
+}
+
+type textureImage struct {
+	texture       uint32
+	width, height int
+}
+
+func (img textureImage) DrawAt(x, y int) {
+	img.draw(x, y, img.bounds(), game.DrawOptions{})
+}
+
+func (img textureImage) DrawAtEx(x, y int, options game.DrawOptions) {
+	img.draw(x, y, img.bounds(), options)
+}
+
+func (img textureImage) DrawRectAt(x, y int, source game.Rectangle) {
+	img.draw(x, y, source, game.DrawOptions{})
+}
+
+func (img textureImage) DrawRectAtEx(x, y int, source game.Rectangle, options game.DrawOptions) {
+	img.draw(x, y, source, options)
+}
+
+func (img textureImage) bounds() game.Rectangle {
+	return game.Rectangle{W: img.width, H: img.height}
+}
+
+// draw queues the image in the sprite batch, it is drawn with all following
+// images that use the same texture.
+func (img textureImage) draw(x, y int, source game.Rectangle, options game.DrawOptions) {
+	quad := spriteQuad(x, y, windowH, img.width, img.height, source, options, 0)
+	sprites.Add(img.texture, quad)
+}
+
+func (img textureImage) Size() (int, int) {
+	return img.width, img.height
+}
+
+// the vertex buffer is reused for every batch to not allocate in every frame
+var quadVertices []batch.Vertex
+
+// drawQuads draws a batch of quads with one call to OpenGL.
+func drawQuads(texture interface{}, quads []batch.Quad) {
+	gl.BindTexture(gl.TEXTURE_2D, texture.(uint32))
+	quadVertices = quadVertices[:0]
+	for i := range quads {
+		quadVertices = quads[i].Triangles(quadVertices)
+	}
+	for i := range quadVertices {
+		quadVertices[i].Color = argbToABGR(quadVertices[i].Color)
+	}
+	const stride = int32(unsafe.Sizeof(batch.Vertex{}))
+	v := &quadVertices[0]
+	gl.VertexPointer(2, gl.FLOAT, stride, unsafe.Pointer(&v.X))
+	gl.TexCoordPointer(2, gl.FLOAT, stride, unsafe.Pointer(&v.U))
+	gl.ColorPointer(4, gl.UNSIGNED_BYTE, stride, unsafe.Pointer(&v.Color))
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(quadVertices)))
+}
+
+// argbToABGR swaps red and blue, OpenGL wants the color's bytes in the order
+// red, green, blue, alpha in memory.
+func argbToABGR(c uint32) uint32 {
+	return c&0xFF00FF00 | c>>16&0xFF | c&0xFF<<16
+}
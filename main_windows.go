@@ -1,30 +1,23 @@
 package main
 
 import (
-	"bytes"
 	"errors"
+	"flag"
 	"fmt"
-	"image"
-	"image/draw"
-	"image/png"
-	"io/ioutil"
-	"math"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"syscall"
-	"time"
 	"unsafe"
 
-	"github.com/gonutz/blob"
 	"github.com/gonutz/d3d9"
-	"github.com/gonutz/mixer"
-	"github.com/gonutz/mixer/wav"
-	"github.com/gonutz/payload"
 	"github.com/gonutz/w32"
 
+	"github.com/gonutz/ld36/audio"
+	"github.com/gonutz/ld36/batch"
 	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/input"
 	"github.com/gonutz/ld36/log"
 )
 
@@ -38,16 +31,17 @@ const (
 )
 
 var (
-	readFile          func(id string) ([]byte, error) = readFileFromDisk
-	rscBlob           *blob.Blob
-	muted             bool
 	previousPlacement w32.WINDOWPLACEMENT
 	device            *d3d9.Device
+	sprites           = batch.New(drawQuads)
 	windowW, windowH  int
 	events            []game.InputEvent
+	inputs            *input.Mapper
 )
 
 func main() {
+	flag.Parse()
+
 	logFile, err := os.Create(filepath.Join(os.Getenv("APPDATA"), "ld36_log.txt"))
 	if err == nil {
 		log.Init(logFile)
@@ -69,19 +63,11 @@ func main() {
 		}
 	}()
 
-	// load the resource blob from the executable
-	rscBlobData, err := payload.Read()
-	if err == nil {
-		rscBlob, err = blob.Read(bytes.NewReader(rscBlobData))
-		if err == nil {
-			readFile = readFileFromBlob
-			log.Printf("blob in exe contains %v item(s)\n", rscBlob.ItemCount())
-		} else {
-			log.Println("unable to decode blob: ", err)
-		}
-	} else {
-		log.Println("unable to read payload:", err)
-	}
+	inputs = input.NewMapper(loadBindings(
+		filepath.Join(os.Getenv("APPDATA"), "ld36_bindings.json"),
+	))
+
+	openAssets()
 
 	// create the window and initialize DirectX
 	window, err := openWindow(
@@ -120,12 +106,13 @@ func main() {
 	windowW = int(client.Right - client.Left)
 	windowH = int(client.Bottom - client.Top)
 
-	err = mixer.Init()
+	soundMixer := audio.NewMixer(audio.DefaultSampleRate)
+	soundDevice, err := audio.OpenDevice(soundMixer)
 	if err != nil {
-		log.Println("unable to initialize the DirectSound8 mixer: ", err)
+		log.Println("unable to open the sound device: ", err)
 		muted = true
 	} else {
-		defer mixer.Close()
+		defer soundDevice.Close()
 	}
 
 	// initialize Direct3D9
@@ -202,9 +189,12 @@ func main() {
 	device.SetTextureStageState(1, d3d9.TSS_COLOROP, d3d9.TOP_DISABLE)
 	device.SetTextureStageState(1, d3d9.TSS_ALPHAOP, d3d9.TOP_DISABLE)
 
-	res := newGameResources()
+	res := newGameResources(soundMixer)
 	defer res.close()
-	g := game.New(res)
+	g, endGame := startGame(res)
+	defer endGame()
+	sim := newSimulation(g)
+	//sim.interpolate = false // NOTE toggle comment to draw only whole updates
 
 	var msg w32.MSG
 	w32.PeekMessage(&msg, 0, 0, 0, w32.PM_NOREMOVE)
@@ -213,16 +203,26 @@ func main() {
 			w32.TranslateMessage(&msg)
 			w32.DispatchMessage(&msg)
 		} else {
+			// a disconnected gamepad reads as the zero state which releases
+			// all its inputs
+			pad, _ := input.ReadXInputPad(0)
+			events = append(events, inputs.SetPad(pad)...)
+
+			g.SetScreenSize(windowW, windowH)
+			t := sim.advance(&events)
+			if g.QuitRequested() {
+				w32.SendMessage(window, w32.WM_CLOSE, 0, 0)
+			}
+
 			device.SetViewport(
 				d3d9.VIEWPORT{0, 0, uint32(windowW), uint32(windowH), 0, 1},
 			)
 			device.Clear(nil, d3d9.CLEAR_TARGET, d3d9.ColorRGB(0, 95, 83), 1, 0)
 			device.BeginScene()
 
-			g.SetScreenSize(windowW, windowH)
-			g.Frame(events)
-			events = events[0:0]
+			g.DrawList(t).Replay(res)
 
+			sprites.Flush()
 			device.EndScene()
 			err := device.Present(
 				&d3d9.RECT{0, 0, int32(windowW), int32(windowH)},
@@ -241,40 +241,42 @@ func main() {
 	}
 }
 
-func addEvent(key game.Key, down bool) {
-	events = append(events, game.InputEvent{
-		Key:  key,
-		Down: down,
-	})
+// keyInputs are the names of the virtual keys that are not letters or digits.
+var keyInputs = map[uintptr]input.Input{
+	w32.VK_LEFT:    input.KeyLeft,
+	w32.VK_RIGHT:   input.KeyRight,
+	w32.VK_UP:      input.KeyUp,
+	w32.VK_DOWN:    input.KeyDown,
+	w32.VK_SPACE:   input.KeySpace,
+	w32.VK_RETURN:  input.KeyEnter,
+	w32.VK_ESCAPE:  input.KeyEscape,
+	w32.VK_SHIFT:   input.KeyShift,
+	w32.VK_CONTROL: input.KeyControl,
+}
+
+func keyInput(vk uintptr) (input.Input, bool) {
+	if 'A' <= vk && vk <= 'Z' || '0' <= vk && vk <= '9' {
+		return input.Input(rune(vk)), true
+	}
+	if w32.VK_F1 <= vk && vk <= w32.VK_F12 {
+		return input.Input(fmt.Sprint("F", vk-w32.VK_F1+1)), true
+	}
+	in, ok := keyInputs[vk]
+	return in, ok
 }
 
 func handleMessage(window w32.HWND, message uint32, w, l uintptr) uintptr {
 	switch message {
 	case w32.WM_KEYUP:
-		switch w {
-		case w32.VK_LEFT:
-			addEvent(game.KeyLeft, false)
-		case w32.VK_RIGHT:
-			addEvent(game.KeyRight, false)
-		case w32.VK_UP, w32.VK_SPACE:
-			addEvent(game.KeyUp, false)
-		case w32.VK_F2:
-			addEvent(game.KeyRestart, false)
+		if in, ok := keyInput(w); ok {
+			events = append(events, inputs.Set(in, false)...)
 		}
 		return 1
 	case w32.WM_KEYDOWN:
-		switch w {
-		case w32.VK_LEFT:
-			addEvent(game.KeyLeft, true)
-		case w32.VK_RIGHT:
-			addEvent(game.KeyRight, true)
-		case w32.VK_UP, w32.VK_SPACE:
-			addEvent(game.KeyUp, true)
-		case w32.VK_F2:
-			addEvent(game.KeyRestart, true)
-		case w32.VK_ESCAPE:
-			w32.SendMessage(window, w32.WM_CLOSE, 0, 0)
-		case w32.VK_F11:
+		if in, ok := keyInput(w); ok {
+			events = append(events, inputs.Set(in, true)...)
+		}
+		if w == w32.VK_F11 {
 			toggleFullscreen(window)
 		}
 		return 1
@@ -363,30 +365,8 @@ func toggleFullscreen(window w32.HWND) {
 	}
 }
 
-func readFileFromDisk(filename string) ([]byte, error) {
-	path := filepath.Join(
-		os.Getenv("GOPATH"),
-		"src",
-		"github.com",
-		"gonutz",
-		"ld36",
-		"rsc",
-		filename,
-	)
-	return ioutil.ReadFile(path)
-}
-
-func readFileFromBlob(id string) (data []byte, err error) {
-	var exists bool
-	data, exists = rscBlob.GetByID(id)
-	if !exists {
-		err = errors.New("resource '" + id + "' does not exist in blob")
-	}
-	return
-}
-
 func mustLoadTexture(id string) (texture *d3d9.Texture, width, height int) {
-	nrgba := toNRGBA(mustLoadPng(id))
+	nrgba := mustLoadImage(id)
 	width, height = nrgba.Bounds().Dx(), nrgba.Bounds().Dy()
 	var err error
 	texture, err = device.CreateTexture(
@@ -413,39 +393,17 @@ func mustLoadTexture(id string) (texture *d3d9.Texture, width, height int) {
 	return
 }
 
-func mustLoadPng(id string) image.Image {
-	data, err := readFile(id + ".png")
-	if err != nil {
-		log.Fatalf("unable to load image %v.png: %v", id, err)
-	}
-	image, err := png.Decode(bytes.NewReader(data))
-	if err != nil {
-		log.Fatalf("image %v.png is not a valid png: %v", id, err)
-	}
-	return image
-}
-
-func toNRGBA(img image.Image) (nrgba *image.NRGBA) {
-	if asNRGBA, ok := img.(*image.NRGBA); ok {
-		nrgba = asNRGBA
-	} else {
-		nrgba = image.NewNRGBA(img.Bounds())
-		draw.Draw(nrgba, nrgba.Bounds(), img, image.ZP, draw.Src)
-	}
-	return
-}
-
-func newGameResources() *resources {
+func newGameResources(mixer *audio.Mixer) *resources {
 	return &resources{
-		images: make(map[string]game.Image),
-		sounds: make(map[string]game.Sound),
+		fileResources: newFileResources(mixer),
+		images:        make(map[string]game.Image),
 	}
 }
 
 type resources struct {
+	fileResources
 	textures []*d3d9.Texture
 	images   map[string]game.Image
-	sounds   map[string]game.Sound
 }
 
 func (r *resources) close() {
@@ -456,74 +414,13 @@ func (r *resources) close() {
 	r.images = make(map[string]game.Image)
 }
 
-func (r *resources) LoadFile(id string) []byte {
-	data, err := readFile(id)
-	if err != nil {
-		log.Fatalf("unable to load file %v: %v", id, err)
-	}
-	log.Printf("loaded file %v (%v bytes)\n", id, len(data))
-	return data
-}
-
-type dummySound struct{}
-
-func (dummySound) Play()        {}
-func (dummySound) PlayLooping() {}
-
-func (r *resources) LoadSound(id string) game.Sound {
-	if muted {
-		return dummySound{}
-	}
-
-	if s, ok := r.sounds[id]; ok {
-		return s
-	}
-
-	soundSource := mustLoadWav(id)
-	r.sounds[id] = sound{source: soundSource}
-
-	return r.sounds[id]
-}
-
-type sound struct {
-	source mixer.SoundSource
-}
-
-func (s sound) PlayLooping() {
-	s.source.PlayOnce()
-	next := time.Tick(s.source.Length())
-	go func() {
-		for {
-			<-next
-			s.source.PlayOnce()
-		}
-	}()
-}
-
-func (s sound) Play() {
-	s.source.PlayOnce()
-}
-
-func mustLoadWav(id string) mixer.SoundSource {
-	data, err := readFile(id + ".wav")
-	if err != nil {
-		log.Fatalf("unable to load sound %v.wav: %v", id, err)
-	}
-
-	wave, err := wav.Read(bytes.NewReader(data))
-	if err != nil {
-		log.Fatalf("unable to read wave %v: %v", id, err)
-	}
-
-	source, err := mixer.NewSoundSource(wave)
-	if err != nil {
-		log.Fatalf("unable to create sound source from wave %v: %v", id, err)
-	}
-
-	return source
+// LoadImage returns sprites as parts of their atlas so that they share its
+// texture.
+func (r *resources) LoadImage(id string) game.Image {
+	return atlases.Load(id, r.loadTexture)
 }
 
-func (r *resources) LoadImage(id string) game.Image {
+func (r *resources) loadTexture(id string) game.Image {
 	if img, ok := r.images[id]; ok {
 		return img
 	}
@@ -551,99 +448,60 @@ func uint32ToFloat32(value uint32) float32 {
 }
 
 func (img textureImage) DrawAt(x, y int) {
-	img.draw(x, y, false, 0, 1)
+	img.draw(x, y, img.bounds(), game.DrawOptions{})
 }
 
 func (img textureImage) DrawAtEx(x, y int, options game.DrawOptions) {
-	img.draw(x, y, options.FlipX, options.CenterRotationDeg, 1-options.Transparency)
+	img.draw(x, y, img.bounds(), options)
 }
 
-func (img textureImage) draw(x, y int, flipX bool, degrees float32, alpha float32) {
-	if err := device.SetTexture(0, img.texture); err != nil {
-		log.Println("DrawAt: device.SetTexture failed:", err)
-		return
-	}
-
-	// the coordinate system for drawing goes from bottom to top
-	y = windowH - 1 - img.height - y
-
-	fx, fy := float32(x), float32(y)
-	fw, fh := float32(img.width), float32(img.height)
-
-	x1, y1 := -fw/2, -fh/2
-	x2, y2 := fw/2, -fh/2
-	x3, y3 := -fw/2, fh/2
-	x4, y4 := fw/2, fh/2
+func (img textureImage) DrawRectAt(x, y int, source game.Rectangle) {
+	img.draw(x, y, source, game.DrawOptions{})
+}
 
-	if flipX {
-		x1, x2, x3, x4 = x2, x1, x4, x3
-	}
+func (img textureImage) DrawRectAtEx(x, y int, source game.Rectangle, options game.DrawOptions) {
+	img.draw(x, y, source, options)
+}
 
-	if degrees != 0 {
-		s, c := math.Sincos(float64(degrees) / 180 * math.Pi)
-		sin, cos := float32(s), float32(c)
-		x1, y1 = cos*x1-sin*y1, sin*x1+cos*y1
-		x2, y2 = cos*x2-sin*y2, sin*x2+cos*y2
-		x3, y3 = cos*x3-sin*y3, sin*x3+cos*y3
-		x4, y4 = cos*x4-sin*y4, sin*x4+cos*y4
-	}
+func (img textureImage) bounds() game.Rectangle {
+	return game.Rectangle{W: img.width, H: img.height}
+}
 
-	dx := fx + fw/2 - 0.5
-	dy := fy + fh/2 - 0.5
-	a := uint32(alpha*255.0+0.5) << 24
-	color := uint32ToFloat32(0xFFFFFF | a)
-	data := [...]float32{
-		x1 + dx, y1 + dy, 0, 1, color, 0, 0,
-		x2 + dx, y2 + dy, 0, 1, color, 1, 0,
-		x3 + dx, y3 + dy, 0, 1, color, 0, 1,
-		x4 + dx, y4 + dy, 0, 1, color, 1, 1,
-	}
-	if err := device.DrawPrimitiveUP(
-		d3d9.PT_TRIANGLESTRIP,
-		2,
-		uintptr(unsafe.Pointer(&data[0])),
-		vertexStride,
-	); err != nil {
-		log.Println("DrawAt: device.DrawPrimitiveUP failed:", err)
-	}
+// draw queues the image in the sprite batch, it is drawn with all following
+// images that use the same texture.
+func (img textureImage) draw(x, y int, source game.Rectangle, options game.DrawOptions) {
+	// Direct3D 9 maps texels to pixels at their top-left corners
+	quad := spriteQuad(x, y, windowH, img.width, img.height, source, options, 0.5)
+	sprites.Add(img.texture, quad)
 }
 
-func (img textureImage) DrawRectAt(x, y int, source game.Rectangle) {
-	if err := device.SetTexture(0, img.texture); err != nil {
-		log.Println("DrawAt: device.SetTexture failed:", err)
+// the vertex buffers are reused for every batch to not allocate in every frame
+var (
+	quadVertices []batch.Vertex
+	quadData     []float32
+)
+
+// drawQuads draws a batch of quads with one call to the device.
+func drawQuads(texture interface{}, quads []batch.Quad) {
+	if err := device.SetTexture(0, texture.(*d3d9.Texture)); err != nil {
+		log.Println("drawQuads: device.SetTexture failed:", err)
 		return
 	}
-
-	// the coordinate system for drawing goes from bottom to top
-	y = windowH - 1 - source.H - y
-
-	fx, fy := float32(x), float32(y)
-	fw, fh := float32(source.W), float32(source.H)
-
-	x1, y1 := -fw/2, -fh/2
-	x2, y2 := fw/2, -fh/2
-	x3, y3 := -fw/2, fh/2
-	x4, y4 := fw/2, fh/2
-
-	dx := fx + fw/2 - 0.5
-	dy := fy + fh/2 - 0.5
-	white := uint32ToFloat32(0xFFFFFFFF)
-	du, dv := 1/float32(img.width), 1/float32(img.height)
-	u0, u1 := float32(source.X)*du, float32(source.X+source.W)*du
-	v0, v1 := float32(source.Y)*dv, float32(source.Y+source.H)*dv
-	data := [...]float32{
-		x1 + dx, y1 + dy, 0, 1, white, u0, v0,
-		x2 + dx, y2 + dy, 0, 1, white, u1, v0,
-		x3 + dx, y3 + dy, 0, 1, white, u0, v1,
-		x4 + dx, y4 + dy, 0, 1, white, u1, v1,
+	quadVertices = quadVertices[:0]
+	for i := range quads {
+		quadVertices = quads[i].Triangles(quadVertices)
+	}
+	quadData = quadData[:0]
+	for _, v := range quadVertices {
+		quadData = append(quadData, v.X, v.Y, 0, 1, uint32ToFloat32(v.Color), v.U, v.V)
 	}
 	if err := device.DrawPrimitiveUP(
-		d3d9.PT_TRIANGLESTRIP,
-		2,
-		uintptr(unsafe.Pointer(&data[0])),
+		d3d9.PT_TRIANGLELIST,
+		uint(2*len(quads)),
+		uintptr(unsafe.Pointer(&quadData[0])),
 		vertexStride,
 	); err != nil {
-		log.Println("DrawAt: device.DrawPrimitiveUP failed:", err)
+		log.Println("drawQuads: device.DrawPrimitiveUP failed:", err)
 	}
 }
 
@@ -0,0 +1,42 @@
+package replay
+
+import "github.com/gonutz/ld36/game"
+
+// Player feeds a replay into a game one frame at a time.
+type Player struct {
+	replay *Replay
+	game   game.Game
+	next   int
+}
+
+// NewPlayer creates a player for g, which must have been started at the
+// replay's LevelIndex, e.g. with game.NewAtLevel.
+func NewPlayer(rp *Replay, g game.Game) *Player {
+	return &Player{replay: rp, game: g}
+}
+
+// Step applies the next recorded frame to the game's Update. It does not draw,
+// call Draw on the game afterwards to see the result. Step returns false without
+// calling the game if all frames were played.
+func (p *Player) Step() bool {
+	if p.Done() {
+		return false
+	}
+	f := p.replay.Frames[p.next]
+	if f.ScreenChanged {
+		p.game.SetScreenSize(f.ScreenW, f.ScreenH)
+	}
+	p.game.Update(f.Events)
+	p.next++
+	return true
+}
+
+// Done returns true after the last frame was played.
+func (p *Player) Done() bool {
+	return p.next >= len(p.replay.Frames)
+}
+
+// FrameIndex is the index of the frame that the next call to Step will play.
+func (p *Player) FrameIndex() int {
+	return p.next
+}
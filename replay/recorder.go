@@ -0,0 +1,75 @@
+package replay
+
+import (
+	"io"
+
+	"github.com/gonutz/ld36/game"
+)
+
+// Recorder is a game.Game that forwards all calls to the wrapped game and
+// writes them to a replay stream. Call Close when done to write the last
+// buffered records.
+type Recorder struct {
+	game             game.Game
+	enc              *encoder
+	screenW, screenH int
+}
+
+// NewRecorder starts recording to w. The levelIndex must be the level that g
+// was started at, the player will start its game at the same level.
+func NewRecorder(g game.Game, w io.Writer, levelIndex int) *Recorder {
+	r := &Recorder{
+		game: g,
+		enc:  newEncoder(w),
+	}
+	r.enc.header(levelIndex)
+	return r
+}
+
+func (r *Recorder) Update(events []game.InputEvent) {
+	r.enc.frame(events)
+	r.game.Update(events)
+}
+
+func (r *Recorder) Draw() {
+	r.game.Draw()
+}
+
+func (r *Recorder) DrawInterpolated(t float32) {
+	r.game.DrawInterpolated(t)
+}
+
+func (r *Recorder) DrawList(t float32) *game.DrawList {
+	return r.game.DrawList(t)
+}
+
+func (r *Recorder) Frame(events []game.InputEvent) {
+	r.enc.frame(events)
+	r.game.Frame(events)
+}
+
+// SetScreenSize only records actual changes since frontends usually call it
+// once per frame.
+func (r *Recorder) SetScreenSize(width, height int) {
+	if width != r.screenW || height != r.screenH {
+		r.screenW, r.screenH = width, height
+		r.enc.screenSize(width, height)
+	}
+	r.game.SetScreenSize(width, height)
+}
+
+func (r *Recorder) QuitRequested() bool {
+	return r.game.QuitRequested()
+}
+
+// Flush writes all buffered records, e.g. before the program might crash.
+func (r *Recorder) Flush() error {
+	r.enc.flushIdle()
+	return r.enc.flush()
+}
+
+// Close writes all buffered records and returns the first write error that
+// occurred while recording. It does not close the underlying writer.
+func (r *Recorder) Close() error {
+	return r.enc.close()
+}
@@ -0,0 +1,234 @@
+// Package replay records the input of a game session and plays it back.
+//
+// Since the game advances exactly once per call to Update and each game seeds
+// its own random source with the level index, feeding the same events into a
+// new game started at the same level reproduces the session exactly.
+//
+// A replay file starts with a header (magic string, format version, start
+// level) followed by a stream of records. Each record starts with an op code:
+//
+//	opFrame      uvarint event count, then one byte per event: Key<<1 | Down
+//	opIdle       uvarint number of consecutive frames without events
+//	opScreenSize uvarint width, uvarint height
+//
+// Restarts are KeyRestart events like in the live game and are replayed as
+// such.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gonutz/ld36/game"
+)
+
+const (
+	magic   = "LD36RPL"
+	version = 1
+)
+
+const (
+	opFrame byte = 1 + iota
+	opIdle
+	opScreenSize
+)
+
+// Frame is one recorded simulation step, i.e. a call to game.Game.Update or
+// game.Game.Frame. If ScreenChanged is true, the screen size changed to
+// ScreenW x ScreenH right before this frame, this might be 0 x 0 if the window
+// was minimized.
+type Frame struct {
+	ScreenChanged    bool
+	ScreenW, ScreenH int
+	Events           []game.InputEvent
+}
+
+// Replay is a decoded replay file.
+type Replay struct {
+	LevelIndex int
+	Frames     []Frame
+}
+
+// Read decodes a whole replay file.
+func Read(r io.Reader) (*Replay, error) {
+	in := bufio.NewReader(r)
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, fmt.Errorf("replay: unable to read header: %v", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("replay: not a replay file")
+	}
+	if v := header[len(magic)]; v != version {
+		return nil, fmt.Errorf("replay: unsupported version %v", v)
+	}
+	level, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, fmt.Errorf("replay: unable to read level index: %v", err)
+	}
+
+	rp := &Replay{LevelIndex: int(level)}
+	var pending Frame
+	for {
+		op, err := in.ReadByte()
+		if err == io.EOF {
+			return rp, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case opFrame:
+			n, err := binary.ReadUvarint(in)
+			if err != nil {
+				return nil, fmt.Errorf("replay: frame %v: %v", len(rp.Frames), err)
+			}
+			f := pending
+			for i := uint64(0); i < n; i++ {
+				b, err := in.ReadByte()
+				if err != nil {
+					return nil, fmt.Errorf("replay: frame %v: %v", len(rp.Frames), err)
+				}
+				f.Events = append(f.Events, decodeEvent(b))
+			}
+			rp.Frames = append(rp.Frames, f)
+			pending = Frame{}
+		case opIdle:
+			n, err := binary.ReadUvarint(in)
+			if err != nil {
+				return nil, fmt.Errorf("replay: frame %v: %v", len(rp.Frames), err)
+			}
+			for i := uint64(0); i < n; i++ {
+				rp.Frames = append(rp.Frames, pending)
+				pending = Frame{}
+			}
+		case opScreenSize:
+			w, err := binary.ReadUvarint(in)
+			if err != nil {
+				return nil, fmt.Errorf("replay: screen size: %v", err)
+			}
+			h, err := binary.ReadUvarint(in)
+			if err != nil {
+				return nil, fmt.Errorf("replay: screen size: %v", err)
+			}
+			pending = Frame{ScreenChanged: true, ScreenW: int(w), ScreenH: int(h)}
+		default:
+			return nil, fmt.Errorf("replay: unknown record type %v", op)
+		}
+	}
+}
+
+// FromSolution creates a replay of a solution that game.Solve found for the
+// level with the given index.
+func FromSolution(levelIndex int, s game.Solution) *Replay {
+	rp := &Replay{
+		LevelIndex: levelIndex,
+		Frames:     make([]Frame, len(s.Frames)),
+	}
+	for i, events := range s.Frames {
+		rp.Frames[i].Events = events
+	}
+	return rp
+}
+
+// Write encodes the replay in the same format that a Recorder produces.
+func (rp *Replay) Write(w io.Writer) error {
+	e := newEncoder(w)
+	e.header(rp.LevelIndex)
+	for _, f := range rp.Frames {
+		if f.ScreenChanged {
+			e.screenSize(f.ScreenW, f.ScreenH)
+		}
+		e.frame(f.Events)
+	}
+	return e.close()
+}
+
+// encoder writes records and collapses runs of empty frames into a single
+// opIdle record. The first write error is kept and returned from close.
+type encoder struct {
+	w    *bufio.Writer
+	idle uint64
+	err  error
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{w: bufio.NewWriter(w)}
+}
+
+func (e *encoder) header(levelIndex int) {
+	e.write([]byte(magic))
+	e.write([]byte{version})
+	e.uvarint(uint64(levelIndex))
+}
+
+func (e *encoder) frame(events []game.InputEvent) {
+	if len(events) == 0 {
+		e.idle++
+		return
+	}
+	e.flushIdle()
+	e.write([]byte{opFrame})
+	e.uvarint(uint64(len(events)))
+	for _, event := range events {
+		e.write([]byte{encodeEvent(event)})
+	}
+}
+
+func (e *encoder) screenSize(w, h int) {
+	e.flushIdle()
+	e.write([]byte{opScreenSize})
+	e.uvarint(uint64(w))
+	e.uvarint(uint64(h))
+}
+
+func (e *encoder) flushIdle() {
+	if e.idle > 0 {
+		e.write([]byte{opIdle})
+		e.uvarint(e.idle)
+		e.idle = 0
+	}
+}
+
+func (e *encoder) flush() error {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+func (e *encoder) close() error {
+	e.flushIdle()
+	return e.flush()
+}
+
+func (e *encoder) uvarint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.write(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func encodeEvent(e game.InputEvent) byte {
+	b := byte(e.Key) << 1
+	if e.Down {
+		b |= 1
+	}
+	return b
+}
+
+func decodeEvent(b byte) game.InputEvent {
+	return game.InputEvent{
+		Key:  game.Key(b >> 1),
+		Down: b&1 != 0,
+	}
+}
@@ -0,0 +1,171 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/ld36/game"
+)
+
+var (
+	leftDown = []game.InputEvent{{Key: game.KeyLeft, Down: true}}
+	jump     = []game.InputEvent{
+		{Key: game.KeyUp, Down: true},
+		{Key: game.KeyLeft, Down: false},
+	}
+)
+
+// record plays a session with idle runs, repeated and 0 x 0 screen sizes and
+// trailing idle frames into a Recorder and returns the calls that reached the
+// game.
+func record(t *testing.T, buf *bytes.Buffer) []string {
+	g := &logGame{}
+	r := NewRecorder(g, buf, 3)
+	r.SetScreenSize(960, 540)
+	r.Update(leftDown)
+	r.Update(nil)
+	r.Update(nil)
+	r.SetScreenSize(960, 540)
+	r.Frame(nil)
+	r.SetScreenSize(0, 0)
+	r.Update(jump)
+	r.SetScreenSize(800, 600)
+	r.Update(nil)
+	r.Update(nil)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return g.calls
+}
+
+func TestRecordedSessionIsReadBack(t *testing.T) {
+	var buf bytes.Buffer
+	record(t, &buf)
+
+	rp, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Replay{
+		LevelIndex: 3,
+		Frames: []Frame{
+			{ScreenChanged: true, ScreenW: 960, ScreenH: 540, Events: leftDown},
+			{},
+			{},
+			{},
+			{ScreenChanged: true, ScreenW: 0, ScreenH: 0, Events: jump},
+			{ScreenChanged: true, ScreenW: 800, ScreenH: 600},
+			{},
+		},
+	}
+	if !reflect.DeepEqual(rp, want) {
+		t.Errorf("want\n%+v\nbut have\n%+v", want, rp)
+	}
+}
+
+func TestWriteProducesRecorderOutput(t *testing.T) {
+	var recorded bytes.Buffer
+	record(t, &recorded)
+	rp, err := Read(bytes.NewReader(recorded.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written bytes.Buffer
+	if err := rp.Write(&written); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written.Bytes(), recorded.Bytes()) {
+		t.Errorf("want\n%v\nbut have\n%v", recorded.Bytes(), written.Bytes())
+	}
+}
+
+func TestPlayerRepeatsRecordedCalls(t *testing.T) {
+	var buf bytes.Buffer
+	calls := record(t, &buf)
+	rp, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &logGame{}
+	p := NewPlayer(rp, g)
+	for p.Step() {
+	}
+	if p.FrameIndex() != len(rp.Frames) {
+		t.Errorf("player stopped at frame %v of %v", p.FrameIndex(), len(rp.Frames))
+	}
+
+	// the recorder forwards every screen size but only changes are replayed
+	// and the player never draws
+	var want []string
+	last := ""
+	for _, c := range calls {
+		if strings.HasPrefix(c, "size") {
+			if c == last {
+				continue
+			}
+			last = c
+		}
+		want = append(want, strings.Replace(c, "frame", "update", 1))
+	}
+	if !reflect.DeepEqual(g.calls, want) {
+		t.Errorf("want calls\n%v\nbut have\n%v",
+			strings.Join(want, "\n"), strings.Join(g.calls, "\n"))
+	}
+}
+
+func TestBrokenFilesAreRejected(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"LD36",
+		"NOTRPL\x01\x00",
+		"LD36RPL\x02\x00",
+		"LD36RPL\x01\x00\x09",
+		"LD36RPL\x01\x00\x01\x02\x04",
+	} {
+		if _, err := Read(strings.NewReader(data)); err == nil {
+			t.Errorf("%q was read without error", data)
+		}
+	}
+}
+
+// logGame logs all calls except drawing.
+type logGame struct {
+	calls []string
+}
+
+func (g *logGame) Update(events []game.InputEvent) {
+	g.calls = append(g.calls, fmt.Sprint("update ", events))
+}
+
+func (g *logGame) Frame(events []game.InputEvent) {
+	g.calls = append(g.calls, fmt.Sprint("frame ", events))
+}
+
+func (g *logGame) SetScreenSize(width, height int) {
+	g.calls = append(g.calls, fmt.Sprint("size ", width, " ", height))
+}
+
+func (*logGame) Draw()                             {}
+func (*logGame) DrawInterpolated(t float32)        {}
+func (*logGame) DrawList(t float32) *game.DrawList { return &game.DrawList{} }
+func (*logGame) QuitRequested() bool               { return false }
+
+func TestSolutionBecomesReplay(t *testing.T) {
+	s := game.Solution{
+		Frames:    [][]game.InputEvent{leftDown, nil, jump, nil},
+		GateFrame: 3,
+	}
+	rp := FromSolution(2, s)
+	want := &Replay{
+		LevelIndex: 2,
+		Frames:     []Frame{{Events: leftDown}, {}, {Events: jump}, {}},
+	}
+	if !reflect.DeepEqual(rp, want) {
+		t.Errorf("want\n%+v\nbut have\n%+v", want, rp)
+	}
+}
@@ -0,0 +1,157 @@
+//go:build linux || windows
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"image"
+	"os"
+
+	"github.com/gonutz/ld36/assets"
+	"github.com/gonutz/ld36/atlas"
+	"github.com/gonutz/ld36/audio"
+	"github.com/gonutz/ld36/game"
+	"github.com/gonutz/ld36/input"
+	"github.com/gonutz/ld36/log"
+	"github.com/gonutz/ld36/rsc/embedded"
+	"github.com/gonutz/ld36/save"
+)
+
+var (
+	rscDir = flag.String("rsc", "rsc", "folder to read the game's files from if they are not in the executable")
+	rsc    *assets.Loader
+	// atlases tells which images are parts of an atlas, it is nil for
+	// resources without atlases
+	atlases *atlas.Manifest
+	muted   bool
+)
+
+// openAssets makes the game's files available through rsc. They are taken from
+// the blob compiled into the executable if there is one, then from the blob
+// appended to it and last from the rsc folder.
+func openAssets() {
+	var err error
+	rsc, err = assets.NewLoader(assets.Options{
+		Embedded: embedded.Blob,
+		Payload:  true,
+		Dir:      *rscDir,
+	})
+	if err != nil {
+		log.Fatal("unable to open the game's files: ", err)
+	}
+	log.Println("reading files from:", rsc.Sources())
+
+	data, err := rsc.ReadFile(atlas.ManifestFile)
+	if err == nil {
+		atlases, err = atlas.ReadManifest(data)
+	}
+	if err != nil && !errors.Is(err, assets.ErrNotFound) {
+		log.Fatal("unable to load the texture atlases: ", err)
+	}
+}
+
+func mustLoadImage(id string) *image.NRGBA {
+	img, err := rsc.LoadImage(id)
+	if err != nil {
+		log.Fatal("unable to load image: ", err)
+	}
+	return img
+}
+
+// fileResources are the parts of game.Resources that all frontends share,
+// they only add LoadImage for their graphics API.
+type fileResources struct {
+	sounds map[string]game.Sound
+	mixer  *audio.Mixer
+}
+
+func newFileResources(mixer *audio.Mixer) fileResources {
+	return fileResources{
+		sounds: make(map[string]game.Sound),
+		mixer:  mixer,
+	}
+}
+
+func (r *fileResources) LoadFile(id string) []byte {
+	data, err := rsc.ReadFile(id)
+	if err != nil {
+		log.Fatal("unable to load file: ", err)
+	}
+	log.Printf("loaded file %v (%v bytes)\n", id, len(data))
+	return data
+}
+
+type dummySound struct{}
+
+func (dummySound) Play() game.SoundInstance        { return dummySound{} }
+func (dummySound) PlayLooping() game.SoundInstance { return dummySound{} }
+func (dummySound) Stop()                           {}
+func (dummySound) Pause()                          {}
+func (dummySound) Resume()                         {}
+func (dummySound) SetVolume(float32)               {}
+func (dummySound) IsPlaying() bool                 { return false }
+
+func (r *fileResources) LoadSound(id string) game.Sound {
+	if muted {
+		return dummySound{}
+	}
+
+	if s, ok := r.sounds[id]; ok {
+		return s
+	}
+
+	wave, err := rsc.LoadWave(id)
+	if err != nil {
+		log.Fatal("unable to load sound: ", err)
+	}
+	r.sounds[id] = r.mixer.NewSound(wave)
+
+	return r.sounds[id]
+}
+
+func (r *fileResources) SetMasterVolume(volume float32) {
+	r.mixer.SetVolume(volume)
+}
+
+func (r *fileResources) SetMuted(muted bool) {
+	r.mixer.SetMuted(muted)
+}
+
+// loadBindings reads the player's key bindings. If there is no bindings file
+// yet, the default bindings are written to it so players can edit them.
+func loadBindings(path string) input.Bindings {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		bindings := input.DefaultBindings()
+		if file, err := os.Create(path); err == nil {
+			defer file.Close()
+			if err := bindings.Write(file); err != nil {
+				log.Println("unable to write default key bindings: ", err)
+			}
+		}
+		return bindings
+	}
+	if err != nil {
+		log.Println("unable to open key bindings, using the defaults: ", err)
+		return input.DefaultBindings()
+	}
+	defer file.Close()
+	bindings, err := input.ReadBindings(file)
+	if err != nil {
+		log.Printf("invalid key bindings in %v, using the defaults: %v\n", path, err)
+		return input.DefaultBindings()
+	}
+	return bindings
+}
+
+// progressStore keeps the player's progress in the user's config directory. If
+// there is none, the progress is not saved.
+func progressStore() game.ProgressStore {
+	dir, err := save.DefaultDir()
+	if err != nil {
+		log.Println("unable to find a directory for the save file: ", err)
+		return nil
+	}
+	return save.NewFileStore(dir)
+}
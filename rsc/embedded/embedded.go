@@ -0,0 +1,10 @@
+// Package embedded holds the game's resource blob when it is compiled into the
+// executable. Running make_assets with -embed rsc/embedded generates blob.go
+// and blob next to this file, after which a plain go build makes a
+// self-contained executable on any system. Without them Blob is empty and the
+// game reads its files from a blob appended to the executable or from the rsc
+// folder.
+package embedded
+
+// Blob is the output of make_assets, it is set by the generated blob.go.
+var Blob []byte
@@ -0,0 +1,84 @@
+// Package save keeps the player's progress in a JSON file.
+package save
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/ld36/game"
+)
+
+// Version is written into every save file. Files with a newer version are not
+// read since this version of the game might lose data in them.
+const Version = 1
+
+// FileName is the name of the save file in its directory.
+const FileName = "progress.json"
+
+// DefaultDir is the game's folder in the user's config directory, e.g.
+// %AppData%\ld36 on Windows or ~/.config/ld36 on Linux.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ld36"), nil
+}
+
+// FileStore implements game.ProgressStore with a file in a directory. The
+// directory is created when the progress is first saved.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+type file struct {
+	Version  int
+	Progress game.Progress
+}
+
+func (s *FileStore) path() string {
+	return filepath.Join(s.dir, FileName)
+}
+
+// LoadProgress returns the zero progress if there is no save file yet.
+func (s *FileStore) LoadProgress() (game.Progress, error) {
+	data, err := ioutil.ReadFile(s.path())
+	if os.IsNotExist(err) {
+		return game.Progress{}, nil
+	}
+	if err != nil {
+		return game.Progress{}, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return game.Progress{}, fmt.Errorf("%v: %v", s.path(), err)
+	}
+	if f.Version < 1 || f.Version > Version {
+		return game.Progress{}, fmt.Errorf("%v: unsupported version %v", s.path(), f.Version)
+	}
+	return f.Progress, nil
+}
+
+// SaveProgress writes to a temporary file first and then replaces the save file
+// with it so a crash while saving does not leave a broken file.
+func (s *FileStore) SaveProgress(p game.Progress) error {
+	data, err := json.MarshalIndent(file{Version: Version, Progress: p}, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0777); err != nil {
+		return err
+	}
+	tmp := s.path() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path())
+}
@@ -0,0 +1,57 @@
+package save
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gonutz/ld36/game"
+)
+
+func TestSavedProgressIsLoaded(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "not", "there", "yet")
+	s := NewFileStore(dir)
+
+	p, err := s.LoadProgress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, game.Progress{}) {
+		t.Errorf("want empty progress without a file but have %+v", p)
+	}
+
+	want := game.Progress{
+		UnlockedLevels: 3,
+		Levels: []game.LevelRecord{
+			{BestUpdates: 500, Restarts: 2},
+			{BestUpdates: 1200},
+		},
+	}
+	if err := s.SaveProgress(want); err != nil {
+		t.Fatal(err)
+	}
+	p, err = NewFileStore(dir).LoadProgress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("want\n%+v\nbut have\n%+v", want, p)
+	}
+}
+
+func TestUnknownVersionsAreRejected(t *testing.T) {
+	for _, data := range []string{
+		`{"Version": 2, "Progress": {"UnlockedLevels": 5}}`,
+		`{"Progress": {"UnlockedLevels": 5}}`,
+		`{"Version": 1,`,
+	} {
+		dir := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(dir, FileName), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewFileStore(dir).LoadProgress(); err == nil {
+			t.Errorf("%v was loaded without error", data)
+		}
+	}
+}